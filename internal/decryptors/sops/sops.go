@@ -0,0 +1,190 @@
+package sops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	sopsv3 "github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/shamir"
+	sopsjson "github.com/getsops/sops/v3/stores/json"
+	sopsyaml "github.com/getsops/sops/v3/stores/yaml"
+	"github.com/kubelize/subst/internal/decryptors"
+)
+
+const (
+	// MetadataField is the field name of the sops metadata
+	MetadataField = "sops"
+)
+
+type SopsDecryptor struct {
+	// stores all age identities for the decryptor
+	identities age.ParsedIdentities
+	// Interface decryptor config
+	Config decryptors.DecryptorConfig
+}
+
+// Initialize a new SOPS Decryptor with age identities read from the given key files.
+// Key files which do not exist are skipped.
+func NewSOPSDecryptor(config decryptors.DecryptorConfig, keyFiles ...string) (*SopsDecryptor, error) {
+	init := &SopsDecryptor{
+		identities: age.ParsedIdentities{},
+		Config:     config,
+	}
+
+	for _, keyFile := range keyFiles {
+		content, err := os.ReadFile(keyFile)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := init.AddKey(string(content)); err != nil {
+			return nil, fmt.Errorf("invalid age key file %s: %w", keyFile, err)
+		}
+	}
+
+	return init, nil
+}
+
+// AddKey adds age identities (one per line, comments are ignored)
+func (d *SopsDecryptor) AddKey(key string) error {
+	return d.identities.Import(key)
+}
+
+func (d *SopsDecryptor) IsEncrypted(data []byte) (bool, error) {
+	if len(data) == 0 {
+		return false, nil
+	}
+
+	content, err := decryptors.UnmarshalJSONorYAML(data)
+	if err != nil {
+		return false, err
+	}
+
+	metadata, ok := content[MetadataField].(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	_, hasMac := metadata["mac"]
+	return hasMac, nil
+}
+
+// Read a sops file (YAML or JSON)
+// Skip decryption still removes the sops metadata
+func (d *SopsDecryptor) Decrypt(data []byte) (content map[string]interface{}, err error) {
+	if !d.Config.SkipDecrypt {
+		data, err = d.read(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	content, err = decryptors.UnmarshalJSONorYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sops: %w", err)
+	}
+
+	// Remove sops metadata
+	delete(content, MetadataField)
+
+	return content, nil
+}
+
+// Attempts to decrypt a sops file with the given identities and verifies its integrity
+func (d *SopsDecryptor) read(data []byte) ([]byte, error) {
+	store := storeFor(data)
+
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sops file: %w", err)
+	}
+
+	key, err := d.dataKey(tree.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Decrypt(key, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops file: %w", err)
+	}
+
+	// Compare the hash of the decrypted tree with the one stored in the document
+	originalMac, err := cipher.Decrypt(
+		tree.Metadata.MessageAuthenticationCode,
+		key,
+		tree.Metadata.LastModified.Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops mac: %w", err)
+	}
+	if originalMac != mac {
+		return nil, fmt.Errorf("failed to verify sops data integrity")
+	}
+
+	return store.EmitPlainFile(tree.Branches)
+}
+
+// dataKey decrypts the data key of the file with the age identities
+func (d *SopsDecryptor) dataKey(metadata sopsv3.Metadata) ([]byte, error) {
+	var parts [][]byte
+	var errs []error
+	for _, group := range metadata.KeyGroups {
+		part, err := d.decryptKeyGroup(group)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	if len(metadata.KeyGroups) <= 1 {
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("could not decrypt with given keys: %w", errors.Join(errs...))
+		}
+		return parts[0], nil
+	}
+
+	if len(parts) < metadata.ShamirThreshold {
+		return nil, fmt.Errorf("could not decrypt %d of %d required key groups with given keys: %w",
+			metadata.ShamirThreshold-len(parts), metadata.ShamirThreshold, errors.Join(errs...))
+	}
+	return shamir.Combine(parts)
+}
+
+// decryptKeyGroup decrypts the data key (part) with the first matching age key of the group
+func (d *SopsDecryptor) decryptKeyGroup(group sopsv3.KeyGroup) ([]byte, error) {
+	var recipients []string
+	for _, masterKey := range group {
+		ageKey, ok := masterKey.(*age.MasterKey)
+		if !ok {
+			continue
+		}
+		recipients = append(recipients, ageKey.Recipient)
+		d.identities.ApplyToMasterKey(ageKey)
+		if key, err := ageKey.Decrypt(); err == nil {
+			return key, nil
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("key group has no age recipients")
+	}
+	return nil, fmt.Errorf("no identity for age recipients %s", strings.Join(recipients, ", "))
+}
+
+// storeFor returns the sops store for the format of data
+func storeFor(data []byte) sopsv3.Store {
+	if json.Valid(data) {
+		return sopsjson.NewStore(&config.JSONStoreConfig{})
+	}
+	return sopsyaml.NewStore(&config.YAMLStoreConfig{})
+}
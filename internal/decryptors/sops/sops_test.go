@@ -0,0 +1,86 @@
+package sops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubelize/subst/internal/decryptors"
+	"github.com/stretchr/testify/assert"
+)
+
+// Generate Key For Testing
+//   $ age-keygen -o testdata/keys.txt
+//   Public key: age1x9nwvrzd7kl3pazhancnvcaevfrvcyz5a4ewcl3htntgddhrayhq0a8stt
+//
+// Encrypt the fixtures with
+//   $ sops encrypt --age age1x9nwvrzd7kl3pazhancnvcaevfrvcyz5a4ewcl3htntgddhrayhq0a8stt plain.yaml > testdata/encrypted.sops.yaml
+
+func testdataPath(file string) string {
+	basePath, _ := os.Getwd()
+	return filepath.Join(basePath, "testdata", file)
+}
+
+func readTestdata(t *testing.T, file string) []byte {
+	content, err := os.ReadFile(testdataPath(file))
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	return content
+}
+
+func TestIsEncrypted(t *testing.T) {
+	decryptor, err := NewSOPSDecryptor(decryptors.DecryptorConfig{})
+	assert.NoError(t, err)
+
+	encrypted, err := decryptor.IsEncrypted(readTestdata(t, "encrypted.sops.yaml"))
+	assert.NoError(t, err)
+	assert.True(t, encrypted, "Expected the sops yaml to be encrypted")
+
+	encrypted, err = decryptor.IsEncrypted(readTestdata(t, "encrypted.sops.json"))
+	assert.NoError(t, err)
+	assert.True(t, encrypted, "Expected the sops json to be encrypted")
+
+	encrypted, err = decryptor.IsEncrypted([]byte("data:\n  key: value\n"))
+	assert.NoError(t, err)
+	assert.False(t, encrypted, "Expected plain yaml not to be encrypted")
+}
+
+func TestDecrypt(t *testing.T) {
+	decryptor, err := NewSOPSDecryptor(decryptors.DecryptorConfig{}, testdataPath("keys.txt"))
+	assert.NoError(t, err)
+
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{
+			"database_password": "VERY_SECRET",
+			"database_user":     "MUCH_SECURE",
+		},
+	}
+	for _, file := range []string{"encrypted.sops.yaml", "encrypted.sops.json"} {
+		content, err := decryptor.Decrypt(readTestdata(t, file))
+		assert.NoError(t, err, file)
+		assert.Equal(t, expected, content, file)
+	}
+}
+
+func TestDecryptWithoutKey(t *testing.T) {
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	t.Setenv("SOPS_AGE_KEY", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	decryptor, err := NewSOPSDecryptor(decryptors.DecryptorConfig{}, testdataPath("missing.txt"))
+	assert.NoError(t, err, "Expected missing key files to be skipped")
+
+	_, err = decryptor.Decrypt(readTestdata(t, "encrypted.sops.yaml"))
+	assert.Error(t, err, "Expected an error without a matching age key")
+}
+
+func TestSkipDecrypt(t *testing.T) {
+	decryptor, err := NewSOPSDecryptor(decryptors.DecryptorConfig{SkipDecrypt: true})
+	assert.NoError(t, err)
+
+	content, err := decryptor.Decrypt(readTestdata(t, "encrypted.sops.yaml"))
+	assert.NoError(t, err)
+	assert.NotContains(t, content, MetadataField, "Expected the sops metadata to be removed")
+	assert.Contains(t, content["data"].(map[string]interface{})["database_password"], "ENC[AES256_GCM")
+}
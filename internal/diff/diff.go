@@ -0,0 +1,270 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces masked values in the diff
+const Redacted = "<redacted>"
+
+// ChangeType is the kind of a change to a resource or field
+type ChangeType string
+
+const (
+	Added    ChangeType = "+"
+	Removed  ChangeType = "-"
+	Modified ChangeType = "~"
+)
+
+// Resource is a rendered resource, identified by group, version, kind, namespace and name
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	Object     map[string]interface{}
+}
+
+// NewResource identifies a rendered object
+func NewResource(object map[string]interface{}) Resource {
+	r := Resource{Object: object}
+	r.APIVersion, _ = object["apiVersion"].(string)
+	r.Kind, _ = object["kind"].(string)
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		r.Namespace, _ = metadata["namespace"].(string)
+		r.Name, _ = metadata["name"].(string)
+	}
+	return r
+}
+
+// ID identifies the resource by GVK, namespace and name
+func (r Resource) ID() string {
+	return strings.Join([]string{r.APIVersion, r.Kind, r.Namespace, r.Name}, "|")
+}
+
+// String identifies the resource as kind namespace/name (apiVersion)
+func (r Resource) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s %s (%s)", r.Kind, r.Name, r.APIVersion)
+	}
+	return fmt.Sprintf("%s %s/%s (%s)", r.Kind, r.Namespace, r.Name, r.APIVersion)
+}
+
+// Field is a change of a single field, identified by its path (eg. spec.containers[0].image)
+type Field struct {
+	Type ChangeType
+	Path string
+	Base interface{}
+	Head interface{}
+}
+
+// ResourceDiff are the changes of a single resource
+type ResourceDiff struct {
+	Type     ChangeType
+	Resource Resource
+	Fields   []Field
+}
+
+// Resources matches base and head resources by GVK, namespace and name and returns the changes.
+// Changed resources are returned in head order, followed by removed resources in base order.
+// Values of Secrets and all values equal to one of the secrets (eg. decrypted values) are redacted.
+func Resources(base, head []Resource, secrets []string) []ResourceDiff {
+	baseByID := make(map[string]Resource, len(base))
+	for _, r := range base {
+		baseByID[r.ID()] = r
+	}
+	headIDs := make(map[string]bool, len(head))
+
+	var diffs []ResourceDiff
+	for _, h := range head {
+		headIDs[h.ID()] = true
+		b, exists := baseByID[h.ID()]
+		if !exists {
+			diffs = append(diffs, ResourceDiff{Type: Added, Resource: h})
+			continue
+		}
+		fields := Objects(mask(b, secrets), mask(h, secrets))
+		if len(fields) > 0 {
+			diffs = append(diffs, ResourceDiff{Type: Modified, Resource: h, Fields: fields})
+		}
+	}
+	for _, b := range base {
+		if !headIDs[b.ID()] {
+			diffs = append(diffs, ResourceDiff{Type: Removed, Resource: b})
+		}
+	}
+	return diffs
+}
+
+// Objects returns the changed fields between base and head, sorted by path
+func Objects(base, head map[string]interface{}) []Field {
+	var fields []Field
+	compare("", base, head, &fields)
+	return fields
+}
+
+func compare(path string, base, head interface{}, fields *[]Field) {
+	baseMap, baseIsMap := base.(map[string]interface{})
+	headMap, headIsMap := head.(map[string]interface{})
+	if baseIsMap && headIsMap {
+		keys := map[string]bool{}
+		for key := range baseMap {
+			keys[key] = true
+		}
+		for key := range headMap {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			b, inBase := baseMap[key]
+			h, inHead := headMap[key]
+			fieldPath := joinPath(path, key)
+			switch {
+			case !inBase:
+				*fields = append(*fields, Field{Type: Added, Path: fieldPath, Head: h})
+			case !inHead:
+				*fields = append(*fields, Field{Type: Removed, Path: fieldPath, Base: b})
+			default:
+				compare(fieldPath, b, h, fields)
+			}
+		}
+		return
+	}
+
+	baseList, baseIsList := base.([]interface{})
+	headList, headIsList := head.([]interface{})
+	if baseIsList && headIsList {
+		for i := 0; i < len(baseList) || i < len(headList); i++ {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(baseList):
+				*fields = append(*fields, Field{Type: Added, Path: itemPath, Head: headList[i]})
+			case i >= len(headList):
+				*fields = append(*fields, Field{Type: Removed, Path: itemPath, Base: baseList[i]})
+			default:
+				compare(itemPath, baseList[i], headList[i], fields)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(base, head) {
+		*fields = append(*fields, Field{Type: Modified, Path: path, Base: base, Head: head})
+	}
+}
+
+// mask replaces the values of Secret data and stringData and all values equal to one of the secrets,
+// changed values are still reported
+func mask(r Resource, secrets []string) map[string]interface{} {
+	object := r.Object
+	if len(secrets) > 0 {
+		object = maskValues(object, secrets).(map[string]interface{})
+	}
+	if r.Kind != "Secret" || r.APIVersion != "v1" {
+		return object
+	}
+	masked := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		masked[key] = value
+		data, ok := value.(map[string]interface{})
+		if !ok || (key != "data" && key != "stringData") {
+			continue
+		}
+		maskedData := make(map[string]interface{}, len(data))
+		for dataKey, dataValue := range data {
+			if secret, ok := dataValue.(secretValue); ok {
+				maskedData[dataKey] = secret
+				continue
+			}
+			maskedData[dataKey] = secretValue{value: fmt.Sprint(dataValue)}
+		}
+		masked[key] = maskedData
+	}
+	return masked
+}
+
+// maskValues replaces all strings equal to one of the secrets
+func maskValues(value interface{}, secrets []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = maskValues(item, secrets)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = maskValues(item, secrets)
+		}
+		return out
+	case string:
+		if slices.Contains(secrets, v) {
+			return secretValue{value: v}
+		}
+	}
+	return value
+}
+
+// secretValue compares by value, but is printed redacted
+type secretValue struct {
+	value string
+}
+
+func (s secretValue) String() string {
+	return Redacted
+}
+
+// Format prints a value of the diff
+func Format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case secretValue:
+		return v.String()
+	}
+	out, err := json.Marshal(unmask(value))
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(out)
+}
+
+// unmask replaces secret values of added or removed subtrees by the redacted placeholder
+func unmask(value interface{}) interface{} {
+	switch v := value.(type) {
+	case secretValue:
+		return Redacted
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = unmask(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = unmask(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func configMap(name string, data map[string]interface{}) Resource {
+	return NewResource(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"data":       data,
+	})
+}
+
+func TestResources(t *testing.T) {
+	base := []Resource{
+		configMap("app", map[string]interface{}{"replicas": "2", "removed": "x"}),
+		configMap("old", nil),
+	}
+	head := []Resource{
+		configMap("app", map[string]interface{}{"replicas": "3", "added": []interface{}{"a"}}),
+		configMap("new", nil),
+	}
+
+	diffs := Resources(base, head, nil)
+	assert.Len(t, diffs, 3)
+
+	assert.Equal(t, Modified, diffs[0].Type)
+	assert.Equal(t, "ConfigMap default/app (v1)", diffs[0].Resource.String())
+	assert.Equal(t, []Field{
+		{Type: Added, Path: "data.added", Head: []interface{}{"a"}},
+		{Type: Removed, Path: "data.removed", Base: "x"},
+		{Type: Modified, Path: "data.replicas", Base: "2", Head: "3"},
+	}, diffs[0].Fields)
+
+	assert.Equal(t, Added, diffs[1].Type)
+	assert.Equal(t, "new", diffs[1].Resource.Name)
+	assert.Equal(t, Removed, diffs[2].Type)
+	assert.Equal(t, "old", diffs[2].Resource.Name)
+}
+
+func TestResourcesMasksSecrets(t *testing.T) {
+	secret := func(password string) Resource {
+		return NewResource(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "app"},
+			"stringData": map[string]interface{}{"password": password},
+		})
+	}
+
+	diffs := Resources([]Resource{secret("one")}, []Resource{secret("two")}, nil)
+	assert.Len(t, diffs, 1)
+	assert.Len(t, diffs[0].Fields, 1, "Expected changed secret values to be reported")
+	field := diffs[0].Fields[0]
+	assert.Equal(t, "stringData.password", field.Path)
+	assert.Equal(t, Redacted, Format(field.Base))
+	assert.Equal(t, Redacted, Format(field.Head))
+
+	assert.Empty(t, Resources([]Resource{secret("one")}, []Resource{secret("one")}, nil))
+}
+
+func TestResourcesMasksSecretValues(t *testing.T) {
+	base := []Resource{configMap("app", map[string]interface{}{"password": "hunter2", "url": "postgres://db", "replicas": "2"})}
+	head := []Resource{configMap("app", map[string]interface{}{"password": "hunter3", "url": "postgres://db-3", "replicas": "3"})}
+
+	diffs := Resources(base, head, []string{"hunter2", "hunter3"})
+	assert.Len(t, diffs, 1)
+	assert.Len(t, diffs[0].Fields, 3)
+	field := diffs[0].Fields[0]
+	assert.Equal(t, "data.password", field.Path)
+	assert.Equal(t, Redacted, Format(field.Base))
+	assert.Equal(t, Redacted, Format(field.Head))
+	assert.Equal(t, Field{Type: Modified, Path: "data.replicas", Base: "2", Head: "3"}, diffs[0].Fields[1])
+	assert.Equal(t, Field{Type: Modified, Path: "data.url", Base: "postgres://db", Head: "postgres://db-3"}, diffs[0].Fields[2])
+}
@@ -0,0 +1,95 @@
+package kustomize
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// metadataFs injects build metadata options into the root kustomization file,
+// without modifying the file on disk
+type metadataFs struct {
+	filesys.FileSystem
+	root     string
+	metadata []string
+}
+
+// SourceTransformer transforms the content of a source file before kustomize reads it
+type SourceTransformer func(path string, content []byte) ([]byte, error)
+
+// sourceFs transforms the source files, without modifying the files on disk
+type sourceFs struct {
+	filesys.FileSystem
+	files     map[string]bool // Absolute paths of the source files
+	transform SourceTransformer
+}
+
+func newFileSystem(root string, metadata []string, sources []string, transform SourceTransformer) filesys.FileSystem {
+	fSys := filesys.MakeFsOnDisk()
+	if transform != nil && len(sources) > 0 {
+		files := make(map[string]bool, len(sources))
+		for _, source := range sources {
+			files[source] = true
+		}
+		fSys = &sourceFs{FileSystem: fSys, files: files, transform: transform}
+	}
+	if len(metadata) == 0 {
+		return fSys
+	}
+	return &metadataFs{FileSystem: fSys, root: filepath.Clean(root), metadata: metadata}
+}
+
+func (f *sourceFs) ReadFile(path string) ([]byte, error) {
+	content, err := f.FileSystem.ReadFile(path)
+	if err != nil || !f.isSource(path) {
+		return content, err
+	}
+	transformed, err := f.transform(path, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transform %s: %w", path, err)
+	}
+	return transformed, nil
+}
+
+// isSource reports whether path is one of the source files
+func (f *sourceFs) isSource(path string) bool {
+	abs, err := filepath.Abs(path)
+	return err == nil && f.files[abs]
+}
+
+func (f *metadataFs) ReadFile(path string) ([]byte, error) {
+	content, err := f.FileSystem.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Dir(filepath.Clean(path)) != f.root ||
+		!slices.Contains(konfig.RecognizedKustomizationFileNames(), filepath.Base(path)) {
+		return content, nil
+	}
+	return addBuildMetadata(content, f.metadata)
+}
+
+// addBuildMetadata adds the given options to the buildMetadata field of a kustomization
+func addBuildMetadata(content []byte, metadata []string) ([]byte, error) {
+	var kustomization map[string]interface{}
+	if err := yaml.Unmarshal(content, &kustomization); err != nil {
+		return nil, fmt.Errorf("failed to parse kustomization file: %w", err)
+	}
+	if kustomization == nil {
+		kustomization = map[string]interface{}{}
+	}
+
+	current, _ := kustomization["buildMetadata"].([]interface{})
+	for _, m := range metadata {
+		if !slices.Contains(current, interface{}(m)) {
+			current = append(current, m)
+		}
+	}
+	kustomization["buildMetadata"] = current
+
+	return yaml.Marshal(kustomization)
+}
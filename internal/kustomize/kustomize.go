@@ -1,142 +1,82 @@
 package kustomize
 
 import (
-	"bytes"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"slices"
 	"strings"
 
-	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
 )
 
 type Kustomize struct {
-	Root             string
-	Paths            []string
-	ResourcePaths    []string // Paths from kustomization.yaml resources field
-	BuildYAML        string
-	BuildOptions     string
+	Root         string
+	Paths        []string // Directories of the kustomization graph, highest precedence first
+	BuildYAML    string
+	Resources    []Resource // Resources of the build output, in output order
+	BuildOptions BuildOptions
 }
 
-// KustomizationFile represents a simplified kustomization.yaml structure
-type KustomizationFile struct {
-	Resources []string `yaml:"resources"`
-}
-
-func NewKustomize(root string, buildOptions string) (*Kustomize, error) {
-	k := &Kustomize{Root: root, BuildOptions: buildOptions}
-	if err := k.build(); err != nil {
-		return nil, err
-	}
-	if err := k.findKustomizationFiles(); err != nil {
-		return nil, err
-	}
-	if err := k.parseKustomizationResources(); err != nil {
-		return nil, err
+// NewKustomize resolves the kustomization graph of root, the resources are built with Build
+func NewKustomize(root string, buildOptions BuildOptions) *Kustomize {
+	return &Kustomize{
+		Root:         root,
+		Paths:        resolvePaths(root),
+		BuildOptions: buildOptions,
 	}
-	return k, nil
 }
 
-func (k *Kustomize) build() error {
-	// Use kustomize binary
-	args := []string{"build", k.Root}
-
-	// Add build options if provided
-	if k.BuildOptions != "" {
-		// Split build options by spaces and add to args
-		options := strings.Fields(k.BuildOptions)
-		args = append(args, options...)
+// Build runs kustomize build. If transform is not nil, the files referenced by the kustomizations of the graph
+// (kustomization files, resources, patches, generator files) are transformed before kustomize reads them.
+// Files on disk are not modified.
+func (k *Kustomize) Build(transform SourceTransformer) error {
+	kustomizer := krusty.MakeKustomizer(k.BuildOptions.krustyOptions())
+
+	// Origin annotations are always collected to attribute resources to their source files,
+	// they are only kept in the output if explicitly requested
+	keepOrigin := slices.Contains(k.BuildOptions.BuildMetadata, types.OriginAnnotations)
+	metadata := k.BuildOptions.BuildMetadata
+	if !keepOrigin {
+		metadata = append(slices.Clone(metadata), types.OriginAnnotations)
 	}
 
-	cmd := exec.Command("kustomize", args...)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	var sources []string
+	if transform != nil {
+		sources = resolveSources(k.Paths)
+	}
 
-	err := cmd.Run()
+	resMap, err := kustomizer.Run(newFileSystem(k.Root, metadata, sources, transform), k.Root)
 	if err != nil {
-		return fmt.Errorf("kustomize build failed: %w, stderr: %s", err, stderr.String())
+		return fmt.Errorf("kustomize build failed: %w", err)
 	}
 
-	k.BuildYAML = stdout.String()
-	return nil
-}
-
-func (k *Kustomize) findKustomizationFiles() error {
-	// Find all kustomization files in the directory tree
-	err := filepath.WalkDir(k.Root, func(path string, d os.DirEntry, err error) error {
+	k.Resources = nil
+	var buildYAML strings.Builder
+	for _, r := range resMap.Resources() {
+		res, err := newResource(r, keepOrigin)
 		if err != nil {
 			return err
 		}
-
-		if d.IsDir() {
-			return nil
+		if buildYAML.Len() > 0 {
+			buildYAML.WriteString("---\n")
 		}
+		buildYAML.Write(res.YAML)
+		k.Resources = append(k.Resources, res)
+	}
 
-		filename := strings.ToLower(d.Name())
-		if filename == "kustomization.yaml" || filename == "kustomization.yml" || filename == "kustomization" {
-			k.Paths = append(k.Paths, filepath.Dir(path))
-		}
-
-		return nil
-	})
-
-	return err
+	k.BuildYAML = buildYAML.String()
+	return nil
 }
 
 func (k *Kustomize) GetYAML() string {
 	return k.BuildYAML
 }
 
-
-func (k *Kustomize) GetResourcePaths() []string {
-	return k.ResourcePaths
+func (k *Kustomize) GetResources() []Resource {
+	return k.Resources
 }
 
-// parseKustomizationResources parses the kustomization.yaml in Root and extracts resource paths
-func (k *Kustomize) parseKustomizationResources() error {
-	// Find the kustomization file
-	var kustomizationPath string
-	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
-		path := filepath.Join(k.Root, name)
-		if _, err := os.Stat(path); err == nil {
-			kustomizationPath = path
-			break
-		}
-	}
-	
-	if kustomizationPath == "" {
-		// No kustomization file found, that's ok
-		return nil
-	}
-	
-	// Read and parse the file
-	content, err := os.ReadFile(kustomizationPath)
-	if err != nil {
-		return err
-	}
-	
-	var kustFile KustomizationFile
-	if err := yaml.Unmarshal(content, &kustFile); err != nil {
-		return fmt.Errorf("failed to parse kustomization file: %w", err)
-	}
-	
-	// Resolve resource paths relative to Root
-	for _, resource := range kustFile.Resources {
-		// Clean the path and resolve it relative to Root
-		resourcePath := filepath.Clean(filepath.Join(k.Root, resource))
-		
-		// Check if it's a directory
-		info, err := os.Stat(resourcePath)
-		if err == nil && info.IsDir() {
-			k.ResourcePaths = append(k.ResourcePaths, resourcePath)
-		}
-	}
-	
-	return nil
-}
+// GetPaths returns the directories of the kustomization graph, highest precedence first
 func (k *Kustomize) GetPaths() []string {
 	return k.Paths
 }
@@ -0,0 +1,83 @@
+package kustomize
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTransformsSources(t *testing.T) {
+	tmp := t.TempDir()
+	overlay := filepath.Join(tmp, "overlay")
+
+	writeFile(t, filepath.Join(tmp, "base", "kustomization.yaml"), "resources:\n  - configmap.yaml\n")
+	writeFile(t, filepath.Join(tmp, "base", "configmap.yaml"), `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`)
+	writeFile(t, filepath.Join(overlay, "kustomization.yaml"), `namespace: NAMESPACE
+resources:
+  - ../base
+`)
+
+	k := NewKustomize(overlay, DefaultBuildOptions())
+	assert.Equal(t, []string{overlay, filepath.Join(tmp, "base")}, k.GetPaths())
+
+	err := k.Build(func(path string, content []byte) ([]byte, error) {
+		return bytes.ReplaceAll(content, []byte("NAMESPACE"), []byte("production")), nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, k.GetResources(), 1)
+	assert.Equal(t, "production", k.GetResources()[0].Namespace)
+	assert.Equal(t, "../base/configmap.yaml", k.GetResources()[0].Origin)
+}
+
+func TestResolvePathsOfTemplatedKustomization(t *testing.T) {
+	tmp := t.TempDir()
+	overlay := filepath.Join(tmp, "overlay")
+
+	writeFile(t, filepath.Join(tmp, "base", "kustomization.yaml"), "resources: []\n")
+	writeFile(t, filepath.Join(overlay, "kustomization.yaml"), `namespace: "{{ .namespace }}"
+resources:
+  - ../base
+replicas:
+  - name: app
+    count: {{ .replicas }}
+`)
+
+	assert.Equal(t, []string{overlay, filepath.Join(tmp, "base")}, resolvePaths(overlay))
+}
+
+func TestBuildReorder(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "kustomization.yaml"), "resources:\n  - resources.yaml\n")
+	writeFile(t, filepath.Join(tmp, "resources.yaml"), `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`)
+
+	for options, kinds := range map[string][]string{
+		"":                 {"Namespace", "ConfigMap"},
+		"--reorder legacy": {"Namespace", "ConfigMap"},
+		"--reorder none":   {"ConfigMap", "Namespace"},
+	} {
+		opts, err := ParseBuildOptions(options)
+		assert.NoError(t, err)
+		k := NewKustomize(tmp, opts)
+		assert.NoError(t, k.Build(nil))
+		var got []string
+		for _, r := range k.GetResources() {
+			got = append(got, r.Kind)
+		}
+		assert.Equal(t, kinds, got, options)
+	}
+}
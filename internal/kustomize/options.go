@@ -0,0 +1,115 @@
+package kustomize
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// BuildOptions are the typed kustomize build options
+type BuildOptions struct {
+	// Restrictions on what can be loaded from the file system
+	LoadRestrictor types.LoadRestrictions
+	// Enable the helmCharts generator
+	EnableHelm bool
+	// Helm command (path to the helm binary)
+	HelmCommand string
+	// Kubernetes api versions used by helm for Capabilities.APIVersions
+	HelmAPIVersions []string
+	// Kubernetes version used by helm for Capabilities.KubeVersion
+	HelmKubeVersion string
+	// Enable kustomize plugins (alpha)
+	EnableAlphaPlugins bool
+	// Enable exec function plugins (alpha)
+	EnableExec bool
+	// Add the app.kubernetes.io/managed-by label to all resources
+	EnableManagedByLabel bool
+	// Build metadata options added to the root kustomization (originAnnotations, transformerAnnotations, managedByLabel)
+	BuildMetadata []string
+	// Output order of the resources (legacy or none), unset uses the sortOptions of the kustomization
+	Reorder krusty.ReorderOption
+}
+
+// DefaultBuildOptions returns the options kustomize build uses without any flags
+func DefaultBuildOptions() BuildOptions {
+	return BuildOptions{
+		LoadRestrictor: types.LoadRestrictionsRootOnly,
+		HelmCommand:    "helm",
+		Reorder:        krusty.ReorderOptionUnspecified,
+	}
+}
+
+// ParseBuildOptions parses kustomize build command line flags (eg. "--load-restrictor LoadRestrictionsNone")
+// into typed build options
+func ParseBuildOptions(options string) (BuildOptions, error) {
+	opts := DefaultBuildOptions()
+
+	var loadRestrictor, reorder string
+	flags := flag.NewFlagSet("kustomize build", flag.ContinueOnError)
+	flags.StringVar(&loadRestrictor, "load-restrictor", types.LoadRestrictionsRootOnly.String(), "")
+	flags.BoolVar(&opts.EnableHelm, "enable-helm", opts.EnableHelm, "")
+	flags.StringVar(&opts.HelmCommand, "helm-command", opts.HelmCommand, "")
+	flags.StringSliceVar(&opts.HelmAPIVersions, "helm-api-versions", opts.HelmAPIVersions, "")
+	flags.StringVar(&opts.HelmKubeVersion, "helm-kube-version", opts.HelmKubeVersion, "")
+	flags.BoolVar(&opts.EnableAlphaPlugins, "enable-alpha-plugins", opts.EnableAlphaPlugins, "")
+	flags.BoolVar(&opts.EnableExec, "enable-exec", opts.EnableExec, "")
+	flags.BoolVar(&opts.EnableManagedByLabel, "enable-managedby-label", opts.EnableManagedByLabel, "")
+	flags.StringSliceVar(&opts.BuildMetadata, "build-metadata", opts.BuildMetadata, "")
+	flags.StringVar(&reorder, "reorder", string(opts.Reorder), "")
+
+	if err := flags.Parse(strings.Fields(options)); err != nil {
+		return opts, fmt.Errorf("invalid kustomize build options %q: %w", options, err)
+	}
+	if flags.NArg() > 0 {
+		return opts, fmt.Errorf("invalid kustomize build options %q: unexpected arguments %v", options, flags.Args())
+	}
+
+	switch loadRestrictor {
+	case types.LoadRestrictionsRootOnly.String():
+		opts.LoadRestrictor = types.LoadRestrictionsRootOnly
+	case types.LoadRestrictionsNone.String():
+		opts.LoadRestrictor = types.LoadRestrictionsNone
+	default:
+		return opts, fmt.Errorf("invalid load restrictor %q", loadRestrictor)
+	}
+
+	switch krusty.ReorderOption(reorder) {
+	case krusty.ReorderOptionUnspecified, krusty.ReorderOptionLegacy, krusty.ReorderOptionNone:
+		opts.Reorder = krusty.ReorderOption(reorder)
+	default:
+		return opts, fmt.Errorf("invalid reorder option %q, must be %q or %q", reorder, krusty.ReorderOptionLegacy, krusty.ReorderOptionNone)
+	}
+
+	for _, m := range opts.BuildMetadata {
+		if !slices.Contains(types.BuildMetadataOptions, m) {
+			return opts, fmt.Errorf("invalid build metadata option %q, must be one of %v", m, types.BuildMetadataOptions)
+		}
+	}
+
+	return opts, nil
+}
+
+// krustyOptions converts the build options into krusty options
+func (o BuildOptions) krustyOptions() *krusty.Options {
+	kOpts := krusty.MakeDefaultOptions()
+	kOpts.Reorder = o.Reorder
+	kOpts.LoadRestrictions = o.LoadRestrictor
+	kOpts.AddManagedbyLabel = o.EnableManagedByLabel
+
+	if o.EnableAlphaPlugins {
+		kOpts.PluginConfig = types.EnabledPluginConfig(types.BploUseStaticallyLinked)
+		kOpts.PluginConfig.FnpLoadingOptions.EnableExec = o.EnableExec
+	}
+	kOpts.PluginConfig.HelmConfig = types.HelmConfig{
+		Enabled:     o.EnableHelm,
+		Command:     o.HelmCommand,
+		ApiVersions: o.HelmAPIVersions,
+		KubeVersion: o.HelmKubeVersion,
+	}
+
+	return kOpts
+}
@@ -0,0 +1,47 @@
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+func TestParseBuildOptions(t *testing.T) {
+	opts, err := ParseBuildOptions("--load-restrictor LoadRestrictionsNone --enable-helm --helm-command helmV3")
+	assert.NoError(t, err)
+	assert.Equal(t, types.LoadRestrictionsNone, opts.LoadRestrictor)
+	assert.True(t, opts.EnableHelm)
+	assert.Equal(t, "helmV3", opts.HelmCommand)
+}
+
+func TestParseBuildOptionsReorder(t *testing.T) {
+	opts, err := ParseBuildOptions("--reorder none")
+	assert.NoError(t, err)
+	assert.Equal(t, krusty.ReorderOptionNone, opts.Reorder)
+	assert.Equal(t, krusty.ReorderOptionNone, opts.krustyOptions().Reorder)
+
+	opts, err = ParseBuildOptions("--reorder=legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, krusty.ReorderOptionLegacy, opts.krustyOptions().Reorder)
+}
+
+func TestParseBuildOptionsDefaults(t *testing.T) {
+	opts, err := ParseBuildOptions("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBuildOptions(), opts)
+}
+
+func TestParseBuildOptionsInvalid(t *testing.T) {
+	for _, options := range []string{
+		"--unknown",
+		"--load-restrictor Everything",
+		"--build-metadata everything",
+		"--reorder random",
+		"positional",
+	} {
+		_, err := ParseBuildOptions(options)
+		assert.Error(t, err, options)
+	}
+}
@@ -0,0 +1,177 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// resolvePaths resolves the directories of the kustomization graph, ordered by precedence (highest first):
+//
+//  1. the kustomization directory itself
+//  2. components, last entry first (resolved recursively)
+//  3. resource directories, last entry first (resolved recursively)
+//  4. directories of patch files, last entry first
+//
+// A directory which is referenced multiple times keeps its highest precedence.
+func resolvePaths(root string) []string {
+	var paths []string
+	resolveKustomizationPaths(filepath.Clean(root), &paths, map[string]bool{})
+	return paths
+}
+
+func resolveKustomizationPaths(dir string, paths *[]string, visiting map[string]bool) {
+	if visiting[dir] {
+		// Circular references are reported by kustomize
+		return
+	}
+	visiting[dir] = true
+	defer delete(visiting, dir)
+
+	appendPath(paths, dir)
+
+	kz, err := kustomizeFile(dir)
+	if err != nil {
+		log.Debug().Msgf("No kustomization in %s: %v", dir, err)
+		return
+	}
+
+	components := kz.Components
+	for i := len(components) - 1; i >= 0; i-- {
+		if path, ok := localDirectory(dir, components[i]); ok {
+			resolveKustomizationPaths(path, paths, visiting)
+		}
+	}
+
+	resources := append(slices.Clone(kz.Resources), kz.Bases...)
+	for i := len(resources) - 1; i >= 0; i-- {
+		if path, ok := localDirectory(dir, resources[i]); ok {
+			resolveKustomizationPaths(path, paths, visiting)
+		}
+	}
+
+	patches := patchFiles(kz)
+	for i := len(patches) - 1; i >= 0; i-- {
+		if isRemoteFile(patches[i]) {
+			continue
+		}
+		path := filepath.Join(dir, patches[i])
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			appendPath(paths, filepath.Dir(path))
+		}
+	}
+}
+
+// patchFiles returns the paths of all patch files in declaration order
+func patchFiles(kz types.Kustomization) []string {
+	var files []string
+	for _, patch := range kz.Patches {
+		if patch.Path != "" {
+			files = append(files, patch.Path)
+		}
+	}
+	for _, patch := range kz.PatchesStrategicMerge {
+		// Inline patches are not files and are skipped by the existence check
+		files = append(files, string(patch))
+	}
+	for _, patch := range kz.PatchesJson6902 {
+		if patch.Path != "" {
+			files = append(files, patch.Path)
+		}
+	}
+	return files
+}
+
+// localDirectory resolves a kustomization entry relative to dir, if it is a local directory
+func localDirectory(dir string, entry string) (string, bool) {
+	if isRemoteFile(entry) {
+		return "", false
+	}
+	path := filepath.Join(dir, entry)
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
+// appendPath adds path, unless it was already added with a higher precedence
+func appendPath(paths *[]string, path string) {
+	if !slices.Contains(*paths, path) {
+		*paths = append(*paths, path)
+	}
+}
+
+// resolveSources returns the files kustomize reads from the kustomizations in dirs: the kustomization files,
+// resource, patch, generator, replacement, helm values and plugin configuration files. Directories, remote
+// and missing files are skipped, directories of the graph contribute their own kustomization file.
+func resolveSources(dirs []string) []string {
+	var sources []string
+	for _, dir := range dirs {
+		kz, err := kustomizeFile(dir)
+		if err != nil {
+			continue
+		}
+		for _, name := range konfig.RecognizedKustomizationFileNames() {
+			appendFile(&sources, dir, name)
+		}
+
+		var files []string
+		files = append(files, kz.Resources...)
+		files = append(files, kz.Bases...)
+		files = append(files, kz.Crds...)
+		files = append(files, kz.Configurations...)
+		files = append(files, kz.Generators...)
+		files = append(files, kz.Transformers...)
+		files = append(files, kz.Validators...)
+		files = append(files, patchFiles(kz)...)
+		for _, r := range kz.Replacements {
+			files = append(files, r.Path)
+		}
+		for _, g := range kz.ConfigMapGenerator {
+			files = append(files, generatorFiles(g.KvPairSources)...)
+		}
+		for _, g := range kz.SecretGenerator {
+			files = append(files, generatorFiles(g.KvPairSources)...)
+		}
+		for _, h := range kz.HelmCharts {
+			files = append(files, h.ValuesFile)
+			files = append(files, h.AdditionalValuesFiles...)
+		}
+		for _, file := range files {
+			if file != "" && !isRemoteFile(file) {
+				appendFile(&sources, dir, file)
+			}
+		}
+	}
+	return sources
+}
+
+// generatorFiles returns the files of a configMap or secret generator ([{key}=]{path} and env files)
+func generatorFiles(kv types.KvPairSources) []string {
+	var files []string
+	for _, source := range kv.FileSources {
+		if _, path, found := strings.Cut(source, "="); found {
+			source = path
+		}
+		files = append(files, source)
+	}
+	files = append(files, kv.EnvSources...)
+	return append(files, kv.EnvSource)
+}
+
+// appendFile adds the absolute path of file relative to dir, if it is an existing regular file
+func appendFile(files *[]string, dir string, file string) {
+	path, err := filepath.Abs(filepath.Join(dir, file))
+	if err != nil {
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		appendPath(files, path)
+	}
+}
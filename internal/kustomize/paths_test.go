@@ -0,0 +1,115 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestResolvePaths(t *testing.T) {
+	tmp := t.TempDir()
+	build := filepath.Join(tmp, "test", "build")
+
+	writeFile(t, filepath.Join(build, "kustomization.yaml"), `
+resources:
+  - operators/
+  - ../addons/values/high-available
+  - deployment.yaml
+components:
+  - ../components/monitoring
+patches:
+  - path: ../../apps/common/patches/argo-appproject.yaml
+  - path: ./patches/argo-app-settings.yaml
+`)
+	writeFile(t, filepath.Join(build, "operators", "kustomization.yaml"), `
+resources:
+  - ../../base
+`)
+	writeFile(t, filepath.Join(tmp, "test", "addons", "values", "high-available", "kustomization.yaml"), "resources: []\n")
+	writeFile(t, filepath.Join(tmp, "test", "components", "monitoring", "kustomization.yaml"), "kind: Component\n")
+	writeFile(t, filepath.Join(tmp, "test", "base", "kustomization.yaml"), "resources: []\n")
+	writeFile(t, filepath.Join(build, "patches", "argo-app-settings.yaml"), "kind: Application\n")
+	writeFile(t, filepath.Join(tmp, "apps", "common", "patches", "argo-appproject.yaml"), "kind: AppProject\n")
+
+	assert.Equal(t, []string{
+		build,
+		filepath.Join(tmp, "test", "components", "monitoring"),
+		filepath.Join(tmp, "test", "addons", "values", "high-available"),
+		filepath.Join(build, "operators"),
+		filepath.Join(tmp, "test", "base"),
+		filepath.Join(build, "patches"),
+		filepath.Join(tmp, "apps", "common", "patches"),
+	}, resolvePaths(build))
+}
+
+func TestResolvePathsKeepsHighestPrecedence(t *testing.T) {
+	tmp := t.TempDir()
+
+	writeFile(t, filepath.Join(tmp, "overlay", "kustomization.yaml"), `
+resources:
+  - ../base
+  - ../app
+`)
+	writeFile(t, filepath.Join(tmp, "app", "kustomization.yaml"), `
+resources:
+  - ../base
+`)
+	writeFile(t, filepath.Join(tmp, "base", "kustomization.yaml"), `
+resources:
+  - ../overlay
+`)
+
+	assert.Equal(t, []string{
+		filepath.Join(tmp, "overlay"),
+		filepath.Join(tmp, "app"),
+		filepath.Join(tmp, "base"),
+	}, resolvePaths(filepath.Join(tmp, "overlay")))
+}
+
+func TestResolveSources(t *testing.T) {
+	tmp := t.TempDir()
+
+	writeFile(t, filepath.Join(tmp, "overlay", "kustomization.yaml"), `
+resources:
+  - ../base
+  - deployment.yaml
+  - https://example.com/remote.yaml
+patches:
+  - path: patch.yaml
+configMapGenerator:
+  - name: config
+    files:
+      - app.properties
+      - renamed=settings.conf
+    envs:
+      - config.env
+`)
+	writeFile(t, filepath.Join(tmp, "overlay", "deployment.yaml"), "kind: Deployment\n")
+	writeFile(t, filepath.Join(tmp, "overlay", "patch.yaml"), "kind: Deployment\n")
+	writeFile(t, filepath.Join(tmp, "overlay", "app.properties"), "a=b\n")
+	writeFile(t, filepath.Join(tmp, "overlay", "settings.conf"), "c=d\n")
+	writeFile(t, filepath.Join(tmp, "overlay", "config.env"), "E=F\n")
+	writeFile(t, filepath.Join(tmp, "overlay", "unreferenced.yaml"), "kind: ConfigMap\n")
+	writeFile(t, filepath.Join(tmp, "base", "kustomization.yaml"), "resources:\n  - service.yaml\n")
+	writeFile(t, filepath.Join(tmp, "base", "service.yaml"), "kind: Service\n")
+	writeFile(t, filepath.Join(tmp, "base", "README.md"), "{{ not a template }}\n")
+
+	assert.Equal(t, []string{
+		filepath.Join(tmp, "overlay", "kustomization.yaml"),
+		filepath.Join(tmp, "overlay", "deployment.yaml"),
+		filepath.Join(tmp, "overlay", "patch.yaml"),
+		filepath.Join(tmp, "overlay", "app.properties"),
+		filepath.Join(tmp, "overlay", "settings.conf"),
+		filepath.Join(tmp, "overlay", "config.env"),
+		filepath.Join(tmp, "base", "kustomization.yaml"),
+		filepath.Join(tmp, "base", "service.yaml"),
+	}, resolveSources(resolvePaths(filepath.Join(tmp, "overlay"))))
+}
@@ -0,0 +1,72 @@
+package kustomize
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// Resource is a single resource of the kustomize build output
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Origin is the file the resource was built from (config.kubernetes.io/origin)
+	Origin string
+	YAML   []byte
+}
+
+func newResource(r *resource.Resource, keepOrigin bool) (Resource, error) {
+	res := Resource{
+		APIVersion: r.GetApiVersion(),
+		Kind:       r.GetKind(),
+		Namespace:  r.GetNamespace(),
+		Name:       r.GetName(),
+	}
+
+	origin, err := r.GetOrigin()
+	if err != nil {
+		return res, fmt.Errorf("failed to read origin of %s: %w", res, err)
+	}
+	res.Origin = originPath(origin)
+
+	if !keepOrigin {
+		if err := r.SetOrigin(nil); err != nil {
+			return res, fmt.Errorf("failed to remove origin of %s: %w", res, err)
+		}
+	}
+
+	res.YAML, err = r.AsYAML()
+	if err != nil {
+		return res, fmt.Errorf("failed to serialize %s: %w", res, err)
+	}
+
+	return res, nil
+}
+
+// originPath returns a readable location for the origin of a resource
+func originPath(origin *resource.Origin) string {
+	if origin == nil {
+		return ""
+	}
+	path := origin.Path
+	if origin.ConfiguredIn != "" {
+		path = origin.ConfiguredIn
+	}
+	if origin.Repo != "" {
+		path = fmt.Sprintf("%s//%s", origin.Repo, path)
+		if origin.Ref != "" {
+			path = fmt.Sprintf("%s?ref=%s", path, origin.Ref)
+		}
+	}
+	return path
+}
+
+// String identifies the resource as kind namespace/name
+func (r Resource) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s %s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
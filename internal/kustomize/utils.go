@@ -1,22 +1,20 @@
 package kustomize
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 
+	"gopkg.in/yaml.v3"
 	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/types"
 )
 
-func convertPath(path string) string {
-	// Ensure path ends with a slash
-	if len(path) > 0 && path[len(path)-1] != filepath.Separator {
-		path += string(filepath.Separator)
-	}
-	return path
-}
+// templateAction matches template actions (eg. {{ .replicas }})
+var templateAction = regexp.MustCompile(`\{\{.*?\}\}`)
 
 func kustomizeFile(path string) (types.Kustomization, error) {
 	kz := types.Kustomization{}
@@ -28,6 +26,16 @@ func kustomizeFile(path string) (types.Kustomization, error) {
 				return kz, err
 			}
 			err = kz.Unmarshal(kzBytes)
+			if err != nil && templateAction.Match(kzBytes) {
+				// Templated kustomizations (eg. count: {{ .replicas }}) are not valid before rendering,
+				// replace the template actions and decode leniently to still resolve the paths
+				var typeErr *yaml.TypeError
+				lenient := types.Kustomization{}
+				err := yaml.Unmarshal(templateAction.ReplaceAll(kzBytes, []byte("0")), &lenient)
+				if err == nil || errors.As(err, &typeErr) {
+					return lenient, nil
+				}
+			}
 
 			return kz, err
 		}
@@ -0,0 +1,203 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// DefaultNaming is the default naming scheme for files written to the output directory
+	DefaultNaming = "<namespace>/<kind>-<name>.yaml"
+	// IndexFileName is the name of the index file in the output directory
+	IndexFileName = ".subst-index.yaml"
+	// ClusterScope is used as namespace for resources without namespace (eg. cluster scoped resources)
+	ClusterScope = "_cluster"
+)
+
+// Index lists all files written to the output directory and the resource they contain
+type Index struct {
+	Files []IndexEntry `yaml:"files"`
+}
+
+// IndexEntry is a single file of the output directory
+type IndexEntry struct {
+	File       string `yaml:"file"`
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Namespace  string `yaml:"namespace,omitempty"`
+	Name       string `yaml:"name"`
+}
+
+// WriteDirectory writes each resource of the manifests to its own file in dir, named by the naming scheme.
+// Files listed in the index of a previous run, which are no longer produced, are removed.
+func WriteDirectory(dir string, naming string, manifests [][]byte) (*Index, error) {
+	if naming == "" {
+		naming = DefaultNaming
+	}
+
+	index := &Index{}
+	contents := map[string]string{}
+	owners := map[string]IndexEntry{}
+	for _, manifest := range manifests {
+		nodes, err := kio.FromBytes(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rendered manifest: %w", err)
+		}
+		for _, node := range nodes {
+			entry, err := newIndexEntry(node, naming)
+			if err != nil {
+				return nil, err
+			}
+			if owner, exists := owners[entry.File]; exists {
+				return nil, fmt.Errorf("%s and %s are both written to %s, use a more specific naming scheme", owner, entry, entry.File)
+			}
+			content, err := node.String()
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize %s: %w", entry, err)
+			}
+			owners[entry.File] = entry
+			contents[entry.File] = content
+			index.Files = append(index.Files, entry)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	previous, err := readIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range index.Files {
+		path := filepath.Join(dir, entry.File)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(contents[entry.File]), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	// Prune files of the previous run, which are no longer produced
+	for _, entry := range previous.Files {
+		if _, exists := contents[entry.File]; exists || !isLocal(entry.File) {
+			continue
+		}
+		log.Debug().Msgf("Pruning %s", entry.File)
+		if err := os.Remove(filepath.Join(dir, entry.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to prune %s: %w", entry.File, err)
+		}
+		removeEmptyDirectories(dir, filepath.Dir(filepath.Join(dir, entry.File)))
+	}
+
+	if err := writeIndex(dir, index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func newIndexEntry(node *kyaml.RNode, naming string) (IndexEntry, error) {
+	entry := IndexEntry{
+		APIVersion: node.GetApiVersion(),
+		Kind:       node.GetKind(),
+		Namespace:  node.GetNamespace(),
+		Name:       node.GetName(),
+	}
+	if entry.Kind == "" || entry.Name == "" {
+		return entry, fmt.Errorf("rendered resource %s has no kind or name", entry)
+	}
+
+	group, version := "core", entry.APIVersion
+	if i := strings.LastIndex(entry.APIVersion, "/"); i >= 0 {
+		group, version = entry.APIVersion[:i], entry.APIVersion[i+1:]
+	}
+	namespace := entry.Namespace
+	if namespace == "" {
+		namespace = ClusterScope
+	}
+
+	file := strings.NewReplacer(
+		"<namespace>", pathSegment(namespace),
+		"<kind>", pathSegment(strings.ToLower(entry.Kind)),
+		"<name>", pathSegment(entry.Name),
+		"<group>", pathSegment(group),
+		"<version>", pathSegment(version),
+	).Replace(naming)
+	file = filepath.Clean(file)
+	if !isLocal(file) || file == IndexFileName {
+		return entry, fmt.Errorf("naming scheme %q results in invalid file %q for %s", naming, file, entry)
+	}
+	entry.File = filepath.ToSlash(file)
+	return entry, nil
+}
+
+// String identifies the entry as kind namespace/name
+func (e IndexEntry) String() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("%s %s", e.Kind, e.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", e.Kind, e.Namespace, e.Name)
+}
+
+// pathSegment makes a value usable as a single path segment
+func pathSegment(value string) string {
+	value = strings.NewReplacer("/", "_", "\\", "_").Replace(value)
+	if value == "." || value == ".." {
+		return "_"
+	}
+	return value
+}
+
+// isLocal reports whether file is relative and stays within its directory
+func isLocal(file string) bool {
+	return filepath.IsLocal(filepath.FromSlash(file))
+}
+
+func readIndex(dir string) (*Index, error) {
+	index := &Index{}
+	content, err := os.ReadFile(filepath.Join(dir, IndexFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, index); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", filepath.Join(dir, IndexFileName), err)
+	}
+	return index, nil
+}
+
+func writeIndex(dir string, index *Index) error {
+	slices.SortFunc(index.Files, func(a, b IndexEntry) int {
+		return strings.Compare(a.File, b.File)
+	})
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(index); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, IndexFileName), content.Bytes(), 0o644)
+}
+
+// removeEmptyDirectories removes path and its parents up to (excluding) dir, as long as they are empty
+func removeEmptyDirectories(dir string, path string) {
+	for path != dir && strings.HasPrefix(path, dir) {
+		if err := os.Remove(path); err != nil {
+			return
+		}
+		path = filepath.Dir(path)
+	}
+}
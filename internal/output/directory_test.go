@@ -0,0 +1,59 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: production
+`
+
+const clusterRole = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:app
+`
+
+func TestWriteDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	index, err := WriteDirectory(dir, "", [][]byte{[]byte(deployment), []byte(clusterRole)})
+	assert.NoError(t, err)
+	assert.Len(t, index.Files, 2)
+
+	content, err := os.ReadFile(filepath.Join(dir, "production", "deployment-app.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, deployment, string(content))
+	assert.FileExists(t, filepath.Join(dir, ClusterScope, "clusterrole-system:app.yaml"))
+	assert.FileExists(t, filepath.Join(dir, IndexFileName))
+
+	// Resources no longer produced are pruned, files not written by subst are kept
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("rendered"), 0o644))
+	_, err = WriteDirectory(dir, "", [][]byte{[]byte(deployment)})
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(dir, ClusterScope))
+	assert.FileExists(t, filepath.Join(dir, "production", "deployment-app.yaml"))
+	assert.FileExists(t, filepath.Join(dir, "README.md"))
+}
+
+func TestWriteDirectoryNaming(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := WriteDirectory(dir, "<group>/<version>/<kind>/<name>.yaml", [][]byte{[]byte(deployment + "---\n" + clusterRole)})
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "apps", "v1", "deployment", "app.yaml"))
+	assert.FileExists(t, filepath.Join(dir, "rbac.authorization.k8s.io", "v1", "clusterrole", "system:app.yaml"))
+
+	_, err = WriteDirectory(dir, "<kind>.yaml", [][]byte{[]byte(deployment), []byte(deployment)})
+	assert.ErrorContains(t, err, "are both written to deployment.yaml")
+
+	_, err = WriteDirectory(dir, "../<name>.yaml", [][]byte{[]byte(deployment)})
+	assert.ErrorContains(t, err, "invalid file")
+}
@@ -0,0 +1,443 @@
+package spruce
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+var (
+	// templateVariable matches template actions using the variables of Prometheus and Alertmanager templates
+	// (eg. {{ $labels.instance }}), which must be escaped to be kept by the template engine. Other variables
+	// (eg. {{ $.cluster.name }} or variables declared with range) are part of subst templates.
+	templateVariable = regexp.MustCompile(`\{\{-?\s*\$(labels|value|externalLabels|externalURL)\b[^}]*\}\}`)
+	// substReference matches template references with the obsolete subst prefix (eg. {{ .subst.cluster.name }})
+	substReference = regexp.MustCompile(`\{\{(-?\s*)\.subst\.`)
+	// identifier matches path segments, which can be used as template field names
+	identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Unconverted is a spruce operator, which could not be converted to a template
+type Unconverted struct {
+	File       string `json:"file,omitempty"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Path       string `json:"path"`
+	Expression string `json:"expression"`
+	Reason     string `json:"reason"`
+}
+
+// Migration is the result of converting the spruce operators of a file to templates
+type Migration struct {
+	Content []byte
+	// Converted is the number of converted operators (including removed merge operators and stripped subst prefixes)
+	Converted int
+	// Escaped is the number of escaped template variables
+	Escaped     int
+	Unconverted []Unconverted
+}
+
+// Changed reports whether the content was modified
+func (m *Migration) Changed() bool {
+	return m.Converted > 0 || m.Escaped > 0
+}
+
+// Migrate converts the spruce operators in the YAML documents of content to templates. The source is edited
+// in place, so formatting and comments are kept. If templated is false (eg. subst.yaml files), the content
+// is not rendered as template and all operators are reported as unconverted.
+func Migrate(content []byte, templated bool) (*Migration, error) {
+	m := &migrator{content: content, templated: templated, lines: lineOffsets(content)}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		}
+		m.walk(&doc, "", nil)
+	}
+
+	// Apply the edits from the end, so the offsets of the remaining edits stay valid
+	sort.Slice(m.edits, func(i, j int) bool { return m.edits[i].start > m.edits[j].start })
+	out := slices.Clone(content)
+	for _, e := range m.edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+
+	result := &Migration{Converted: len(m.edits), Unconverted: m.unconverted}
+	if templated {
+		out = substReference.ReplaceAllFunc(out, func(match []byte) []byte {
+			result.Converted++
+			return substReference.ReplaceAll(match, []byte("{{$1."))
+		})
+		out = escapeVariables(out, m.variables, &result.Escaped)
+	}
+	result.Content = out
+	return result, nil
+}
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+type migrator struct {
+	content     []byte
+	templated   bool
+	lines       []int
+	edits       []edit
+	unconverted []Unconverted
+	// variables are template actions with variables found in values
+	variables []string
+}
+
+func (m *migrator) walk(node *yaml.Node, path string, parent *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			m.walk(n, path, node)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			m.walk(node.Content[i+1], join(path, node.Content[i].Value), node)
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			m.walk(n, join(path, strconv.Itoa(i)), node)
+		}
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			return
+		}
+		if !IsOperator(node.Value) {
+			m.variables = append(m.variables, templateVariable.FindAllString(node.Value, -1)...)
+			return
+		}
+		if err := m.convert(node, parent, path); err != nil {
+			m.unconverted = append(m.unconverted, Unconverted{
+				Line:       node.Line,
+				Column:     node.Column,
+				Path:       path,
+				Expression: strings.TrimSpace(node.Value),
+				Reason:     err.Error(),
+			})
+		}
+	}
+}
+
+// convert replaces the source of an operator node with its template
+func (m *migrator) convert(node *yaml.Node, parent *yaml.Node, path string) error {
+	if !m.templated {
+		return errors.New("file is not rendered as template, evaluate it with --spruce or move the value to a template")
+	}
+	if strings.Contains(node.Value, "\n") {
+		return errors.New("operators spanning multiple lines are not supported")
+	}
+	expr, err := parse(node.Value)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := m.span(node)
+	if err != nil {
+		return err
+	}
+
+	if isMergeOperator(node.Value) {
+		if expr.operator != "append" {
+			return fmt.Errorf("merge operator %q has no template equivalent", expr.operator)
+		}
+		if parent == nil || parent.Kind != yaml.SequenceNode || parent.Style&yaml.FlowStyle != 0 {
+			return errors.New("append is only removed from block lists")
+		}
+		lineStart, lineEnd := m.line(node.Line)
+		prefix := strings.TrimSpace(string(m.content[lineStart:start]))
+		suffix := strings.TrimSpace(string(m.content[end:lineEnd]))
+		if prefix != "-" || (suffix != "" && !strings.HasPrefix(suffix, "#")) {
+			return errors.New("append is only removed from list items on their own line")
+		}
+		m.edits = append(m.edits, edit{start: lineStart, end: lineEnd, text: ""})
+		return nil
+	}
+
+	if expr.operator == "stringify" {
+		return m.convertStringify(node, parent, path, expr, start, end)
+	}
+
+	tmpl, err := template(expr)
+	if err != nil {
+		return err
+	}
+	m.edits = append(m.edits, edit{start: start, end: end, text: quote(tmpl)})
+	return nil
+}
+
+// convertStringify replaces a stringify operator with a literal block rendering toYAML. As with the native
+// evaluation the value is multi-line YAML, which can not be rendered into a quoted scalar. The rendered lines
+// are indented more than the key in the source and in the kustomize output, so the block is valid in both.
+func (m *migrator) convertStringify(node *yaml.Node, parent *yaml.Node, path string, expr *expression, start, end int) error {
+	if len(expr.args) != 1 {
+		return errors.New("stringify requires exactly one argument")
+	}
+	if parent == nil || (parent.Kind != yaml.MappingNode && parent.Kind != yaml.SequenceNode) || parent.Style&yaml.FlowStyle != 0 {
+		return errors.New("stringify is only converted in block mappings and lists")
+	}
+	lineStart, lineEnd := m.line(node.Line)
+	comment := strings.TrimSpace(string(m.content[end:lineEnd]))
+	if comment != "" && !strings.HasPrefix(comment, "#") {
+		return errors.New("stringify is only converted at the end of a line")
+	}
+	pipeline, err := alternatives(expr.args[0])
+	if err != nil {
+		return err
+	}
+
+	line := string(m.content[lineStart:lineEnd])
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	// Kustomize indents each level of the path by at most two spaces
+	width := max(indent, 2*strings.Count(path, ".")) + 2
+
+	var b strings.Builder
+	b.WriteString("|")
+	if comment != "" {
+		b.WriteString(" " + comment)
+	}
+	b.WriteString("\n" + strings.Repeat(" ", width))
+	b.WriteString("{{- toYAML " + parenthesize(pipeline) + " | indent " + strconv.Itoa(width) + ` | printf "\n%s" }}` + "\n")
+	m.edits = append(m.edits, edit{start: start, end: lineEnd, text: b.String()})
+	return nil
+}
+
+// span returns the source offsets of a single line scalar node, including quotes
+func (m *migrator) span(node *yaml.Node) (int, int, error) {
+	if node.Line < 1 || node.Line > len(m.lines) {
+		return 0, 0, errors.New("could not locate operator in the source")
+	}
+	lineStart, lineEnd := m.line(node.Line)
+	start := lineStart
+	for i := 1; i < node.Column && start < lineEnd; i++ {
+		_, size := utf8.DecodeRune(m.content[start:])
+		start += size
+	}
+
+	source := string(m.content[start:lineEnd])
+	end := -1
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0 && strings.HasPrefix(source, `"`):
+		for i := 1; i < len(source); i++ {
+			if source[i] == '\\' {
+				i++
+			} else if source[i] == '"' {
+				end = start + i + 1
+				break
+			}
+		}
+	case node.Style&yaml.SingleQuotedStyle != 0 && strings.HasPrefix(source, `'`):
+		for i := 1; i < len(source); i++ {
+			if source[i] == '\'' {
+				if i+1 < len(source) && source[i+1] == '\'' {
+					i++
+					continue
+				}
+				end = start + i + 1
+				break
+			}
+		}
+	case node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 && strings.HasPrefix(source, node.Value):
+		end = start + len(node.Value)
+	}
+	if end < 0 {
+		return 0, 0, errors.New("could not locate operator in the source")
+	}
+	return start, end, nil
+}
+
+// line returns the offsets of a line (1-based), including the line break
+func (m *migrator) line(n int) (int, int) {
+	end := len(m.content)
+	if n < len(m.lines) {
+		end = m.lines[n]
+	}
+	return m.lines[n-1], end
+}
+
+// template converts an operator expression to a template
+func template(expr *expression) (string, error) {
+	switch expr.operator {
+	case "grab":
+		if len(expr.args) != 1 {
+			return "", errors.New("grab with multiple arguments has no template equivalent")
+		}
+		pipeline, err := alternatives(expr.args[0])
+		if err != nil {
+			return "", err
+		}
+		return "{{ " + pipeline + " }}", nil
+	case "concat":
+		var b strings.Builder
+		for _, arg := range expr.args {
+			if len(arg) == 1 && arg[0].kind == literalOperand {
+				text, err := scalar(arg[0].value)
+				if err != nil {
+					return "", err
+				}
+				if strings.Contains(text, "{{") || strings.Contains(text, "}}") {
+					text = "{{ " + strconv.Quote(text) + " }}"
+				}
+				b.WriteString(text)
+				continue
+			}
+			pipeline, err := alternatives(arg)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString("{{ " + pipeline + " }}")
+		}
+		return b.String(), nil
+	case "join":
+		if len(expr.args) != 2 || len(expr.args[0]) != 1 || expr.args[0][0].kind != literalOperand {
+			return "", errors.New("join is only converted with a literal separator and a single list")
+		}
+		pipeline, err := alternatives(expr.args[1])
+		if err != nil {
+			return "", err
+		}
+		separator, err := scalar(expr.args[0][0].value)
+		if err != nil {
+			return "", err
+		}
+		return "{{ join " + parenthesize(pipeline) + " " + strconv.Quote(separator) + " }}", nil
+	}
+	return "", fmt.Errorf("operator %q has no template equivalent", expr.operator)
+}
+
+// alternatives converts a || b || "default" to a pipeline with defaults
+func alternatives(arg argument) (string, error) {
+	var pipeline string
+	for i := len(arg) - 1; i >= 0; i-- {
+		value, err := templateOperand(arg[i])
+		if err != nil {
+			return "", err
+		}
+		if i == len(arg)-1 {
+			pipeline = value
+		} else {
+			pipeline = value + " | default " + parenthesize(pipeline)
+		}
+	}
+	return pipeline, nil
+}
+
+// templateOperand converts a reference or literal to a template argument
+func templateOperand(o operand) (string, error) {
+	if o.kind == literalOperand {
+		switch v := o.value.(type) {
+		case nil:
+			return "nil", nil
+		case string:
+			return strconv.Quote(v), nil
+		default:
+			return scalar(v)
+		}
+	}
+
+	reference := strings.TrimPrefix(strings.TrimPrefix(o.reference, "$"), ".")
+	if reference == SubstPrefix {
+		return ".", nil
+	}
+	path, ok := strings.CutPrefix(reference, SubstPrefix+".")
+	if !ok {
+		return "", fmt.Errorf("reference %s is not in the substitutions ($.subst), templates can not reference the document", o.reference)
+	}
+
+	segments := strings.Split(strings.NewReplacer("[", ".", "]", "").Replace(path), ".")
+	fields := true
+	for _, s := range segments {
+		if !identifier.MatchString(s) {
+			fields = false
+		}
+	}
+	if fields {
+		return "." + strings.Join(segments, "."), nil
+	}
+
+	// Keys which are not identifiers (eg. kubernetes-dashboard) and list indexes require index
+	args := []string{"index", "."}
+	for _, s := range segments {
+		if _, err := strconv.Atoi(s); err == nil {
+			args = append(args, s)
+		} else {
+			args = append(args, strconv.Quote(s))
+		}
+	}
+	return strings.Join(args, " "), nil
+}
+
+func parenthesize(pipeline string) string {
+	if strings.ContainsFunc(pipeline, unicode.IsSpace) {
+		return "(" + pipeline + ")"
+	}
+	return pipeline
+}
+
+// quote returns a YAML scalar for the template, single quoted unless it contains single quotes
+func quote(tmpl string) string {
+	if !strings.Contains(tmpl, "'") {
+		return "'" + tmpl + "'"
+	}
+	return strconv.Quote(tmpl)
+}
+
+// escapeVariables escapes template actions with variables, which are not escaped yet
+func escapeVariables(content []byte, variables []string, count *int) []byte {
+	seen := map[string]bool{}
+	for _, v := range variables {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+
+		var out []byte
+		rest := content
+		for {
+			i := bytes.Index(rest, []byte(v))
+			if i < 0 {
+				break
+			}
+			if i > 0 && rest[i-1] == '`' {
+				out = append(out, rest[:i+len(v)]...)
+			} else {
+				out = append(out, rest[:i]...)
+				out = append(out, "{{`"+v+"`}}"...)
+				*count++
+			}
+			rest = rest[i+len(v):]
+		}
+		content = append(out, rest...)
+	}
+	return content
+}
+
+// lineOffsets returns the offset of the start of each line
+func lineOffsets(content []byte) []int {
+	offsets := []int{0}
+	for i, c := range content {
+		if c == '\n' && i+1 < len(content) {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
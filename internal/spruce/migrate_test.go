@@ -0,0 +1,100 @@
+package spruce
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	content := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app # the app
+data:
+  cluster: (( grab $.subst.cluster.name ))
+  host: "(( grab $.subst.settings.proxy.host ))"
+  missing: (( grab $.subst.settings.missing || "default" ))
+  url: (( concat "http://" $.subst.settings.proxy.host ":" $.subst.settings.proxy.port ))
+  dashboard: (( grab $.subst.kubernetes-dashboard.hosts.0 ))
+  proxy: (( stringify $.subst.settings.proxy || "" ))
+  zones: (( join "," $.subst.cluster.zones ))
+  alert: "{{ $labels.instance }} is down, {{` + "`{{ $value }}`" + `}}"
+  self: (( grab metadata.name ))
+  legacy: "{{ .subst.cluster.name }}"
+spec:
+  values: ["default", (( grab $.subst.cluster.name ))]
+  zones:
+  - (( append ))
+  - a
+`)
+
+	m, err := Migrate(content, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app # the app
+data:
+  cluster: '{{ .cluster.name }}'
+  host: '{{ .settings.proxy.host }}'
+  missing: '{{ .settings.missing | default "default" }}'
+  url: 'http://{{ .settings.proxy.host }}:{{ .settings.proxy.port }}'
+  dashboard: '{{ index . "kubernetes-dashboard" "hosts" 0 }}'
+  proxy: |
+    {{- toYAML (.settings.proxy | default "") | indent 4 | printf "\n%s" }}
+  zones: '{{ join .cluster.zones "," }}'
+  alert: "{{`+"`{{ $labels.instance }}`"+`}} is down, {{`+"`{{ $value }}`"+`}}"
+  self: (( grab metadata.name ))
+  legacy: "{{ .cluster.name }}"
+spec:
+  values: ["default", '{{ .cluster.name }}']
+  zones:
+  - a
+`, string(m.Content))
+	assert.Equal(t, 10, m.Converted)
+	assert.Equal(t, 1, m.Escaped)
+	assert.Equal(t, []Unconverted{{
+		Line:       14,
+		Column:     9,
+		Path:       "data.self",
+		Expression: "(( grab metadata.name ))",
+		Reason:     "reference metadata.name is not in the substitutions ($.subst), templates can not reference the document",
+	}}, m.Unconverted)
+
+	again, err := Migrate(m.Content, true)
+	assert.NoError(t, err)
+	assert.False(t, again.Changed())
+}
+
+func TestMigrateTemplated(t *testing.T) {
+	content := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "{{ $.cluster.name }}"
+data:
+  labels: "{{ range $k, $v := .labels }}{{ $k }}={{ $v }},{{ end }}"
+  alert: "{{ $labels.instance }} is {{ $value }}"
+`)
+
+	m, err := Migrate(content, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "{{ $.cluster.name }}"
+data:
+  labels: "{{ range $k, $v := .labels }}{{ $k }}={{ $v }},{{ end }}"
+  alert: "{{`+"`{{ $labels.instance }}`"+`}} is {{`+"`{{ $value }}`"+`}}"
+`, string(m.Content))
+	assert.Equal(t, 2, m.Escaped)
+}
+
+func TestMigrateNotTemplated(t *testing.T) {
+	content := []byte("image: (( concat \"registry/\" $.subst.name ))\n")
+	m, err := Migrate(content, false)
+	assert.NoError(t, err)
+	assert.Equal(t, string(content), string(m.Content))
+	assert.False(t, m.Changed())
+	assert.Len(t, m.Unconverted, 1)
+}
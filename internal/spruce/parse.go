@@ -0,0 +1,155 @@
+package spruce
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// operatorPattern matches a value which is a spruce operator call, eg. (( grab $.subst.cluster.name ))
+var operatorPattern = regexp.MustCompile(`^\(\(\s*(.*?)\s*\)\)$`)
+
+// expression is a parsed operator call
+type expression struct {
+	operator string
+	args     []argument
+}
+
+// argument is a list of alternatives, the first one which resolves is used (a || b || "default")
+type argument []operand
+
+type operandKind int
+
+const (
+	referenceOperand operandKind = iota
+	literalOperand
+)
+
+type operand struct {
+	kind operandKind
+	// reference is the path of a reference operand (eg. $.subst.cluster.name)
+	reference string
+	// value is the value of a literal operand
+	value interface{}
+}
+
+func (o operand) String() string {
+	if o.kind == referenceOperand {
+		return o.reference
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(o.value))
+}
+
+// IsOperator reports whether value is a spruce operator call
+func IsOperator(value string) bool {
+	return operatorPattern.MatchString(strings.TrimSpace(value))
+}
+
+// parse parses an operator call, eg. (( concat "prefix-" $.subst.name || "default" ))
+func parse(value string) (*expression, error) {
+	match := operatorPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return nil, fmt.Errorf("%q is not a spruce operator", value)
+	}
+
+	tokens, err := tokenize(match[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid operator %s: %w", value, err)
+	}
+	if len(tokens) == 0 || tokens[0].quoted {
+		return nil, fmt.Errorf("invalid operator %s: missing operator name", value)
+	}
+
+	expr := &expression{operator: tokens[0].value}
+	var current argument
+	expectOperand := true
+	for _, t := range tokens[1:] {
+		if !t.quoted && t.value == "||" {
+			if expectOperand {
+				return nil, fmt.Errorf("invalid operator %s: unexpected ||", value)
+			}
+			expectOperand = true
+			continue
+		}
+		if !expectOperand {
+			expr.args = append(expr.args, current)
+			current = nil
+		}
+		current = append(current, newOperand(t))
+		expectOperand = false
+	}
+	if expectOperand && len(current) > 0 {
+		return nil, fmt.Errorf("invalid operator %s: missing operand after ||", value)
+	}
+	if len(current) > 0 {
+		expr.args = append(expr.args, current)
+	}
+	return expr, nil
+}
+
+type token struct {
+	value  string
+	quoted bool
+}
+
+// tokenize splits the operator call into words and quoted strings
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		switch c := input[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(input) && input[end] != c {
+				if input[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("unterminated string %s", input[i:])
+			}
+			value := input[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(input[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string %s: %w", input[i:end+1], err)
+				}
+				value = unquoted
+			}
+			tokens = append(tokens, token{value: value, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(input) && !strings.ContainsRune(" \t\n\"'", rune(input[end])) {
+				end++
+			}
+			tokens = append(tokens, token{value: input[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func newOperand(t token) operand {
+	if t.quoted {
+		return operand{kind: literalOperand, value: t.value}
+	}
+	switch t.value {
+	case "nil", "null", "~":
+		return operand{kind: literalOperand, value: nil}
+	case "true":
+		return operand{kind: literalOperand, value: true}
+	case "false":
+		return operand{kind: literalOperand, value: false}
+	}
+	if i, err := strconv.Atoi(t.value); err == nil {
+		return operand{kind: literalOperand, value: i}
+	}
+	if f, err := strconv.ParseFloat(t.value, 64); err == nil {
+		return operand{kind: literalOperand, value: f}
+	}
+	return operand{kind: referenceOperand, reference: t.value}
+}
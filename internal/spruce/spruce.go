@@ -0,0 +1,401 @@
+// Package spruce evaluates the common spruce operators (grab, concat, stringify, join) natively,
+// so repositories using spruce render without the spruce binary while they migrate to templates.
+package spruce
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SubstPrefix is the prefix of references into the substitutions (eg. $.subst.cluster.name)
+const SubstPrefix = "subst"
+
+// maxDepth limits the evaluation of references to values which are operators themselves
+const maxDepth = 32
+
+// errNested is returned for references which are nested deeper than maxDepth
+var errNested = errors.New("references are nested too deep (cyclic reference?)")
+
+// mergeOperators only control how spruce merges lists, they are removed from the output
+var mergeOperators = []string{"append", "prepend", "inline", "replace", "merge"}
+
+// Evaluate replaces all spruce operators in the YAML documents of content. References starting
+// with $.subst (or subst) are resolved against data, all other references against the document itself.
+func Evaluate(content []byte, data map[string]interface{}) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		}
+		docs = append(docs, &doc)
+	}
+
+	var out bytes.Buffer
+	for i, doc := range docs {
+		var document interface{}
+		if err := doc.Decode(&document); err != nil {
+			return nil, fmt.Errorf("failed to decode document %d: %w", i, err)
+		}
+		e := &evaluator{data: data, document: document}
+		if err := e.walk(doc); err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		encoder := yaml.NewEncoder(&out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to encode document %d: %w", i, err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// EvaluateData replaces all spruce operators in the values of data, references are resolved against data itself
+func EvaluateData(data map[string]interface{}) (map[string]interface{}, error) {
+	e := &evaluator{data: data, document: data}
+	result, err := e.value(data, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := result.(map[string]interface{}); ok {
+		return m, nil
+	}
+	return data, nil
+}
+
+// Contains reports whether content contains anything which looks like a spruce operator
+func Contains(content []byte) bool {
+	return bytes.Contains(content, []byte("(("))
+}
+
+type evaluator struct {
+	data     map[string]interface{}
+	document interface{}
+}
+
+// walk replaces operators in the node tree in place
+func (e *evaluator) walk(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			if err := e.walk(n); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := e.walk(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		items := node.Content[:0]
+		for _, n := range node.Content {
+			if n.Kind == yaml.ScalarNode && isMergeOperator(n.Value) {
+				continue
+			}
+			if err := e.walk(n); err != nil {
+				return err
+			}
+			items = append(items, n)
+		}
+		node.Content = items
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" || !IsOperator(node.Value) {
+			return nil
+		}
+		result, err := e.operator(node.Value, 0)
+		if err != nil {
+			return err
+		}
+		var replacement yaml.Node
+		if err := replacement.Encode(result); err != nil {
+			return fmt.Errorf("failed to encode result of %s: %w", node.Value, err)
+		}
+		if replacement.Kind == yaml.ScalarNode && replacement.Tag == "!!str" && !strings.Contains(replacement.Value, "\n") {
+			replacement.Style = node.Style
+		}
+		replacement.HeadComment, replacement.LineComment, replacement.FootComment = node.HeadComment, node.LineComment, node.FootComment
+		*node = replacement
+	}
+	return nil
+}
+
+// value replaces operators in a decoded value
+func (e *evaluator) value(v interface{}, path string, depth int) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(t))
+		for key, item := range t {
+			evaluated, err := e.value(item, join(path, key), depth)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = evaluated
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(t))
+		for i, item := range t {
+			if s, ok := item.(string); ok && isMergeOperator(s) {
+				continue
+			}
+			evaluated, err := e.value(item, join(path, strconv.Itoa(i)), depth)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, evaluated)
+		}
+		return result, nil
+	case string:
+		if !IsOperator(t) {
+			return t, nil
+		}
+		result, err := e.operator(t, depth)
+		if err != nil && path != "" {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return result, err
+	}
+	return v, nil
+}
+
+// operator evaluates a single operator call
+func (e *evaluator) operator(call string, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errNested
+	}
+	expr, err := parse(call)
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(expr.args))
+	for i, arg := range expr.args {
+		if args[i], err = e.argument(arg, depth); err != nil {
+			if errors.Is(err, errNested) && depth > 0 {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", call, err)
+		}
+	}
+
+	var result interface{}
+	switch expr.operator {
+	case "grab":
+		result, err = grab(args)
+	case "concat":
+		result, err = concat(args)
+	case "stringify":
+		result, err = stringify(args)
+	case "join":
+		result, err = joinValues(args)
+	default:
+		return nil, fmt.Errorf("%s: unsupported spruce operator %q (supported: grab, concat, stringify, join)", call, expr.operator)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", call, err)
+	}
+	return result, nil
+}
+
+// argument returns the value of the first alternative which resolves
+func (e *evaluator) argument(arg argument, depth int) (interface{}, error) {
+	var unresolved []string
+	for _, o := range arg {
+		if o.kind == literalOperand {
+			return o.value, nil
+		}
+		v, found := e.resolve(o.reference)
+		if !found {
+			unresolved = append(unresolved, o.reference)
+			continue
+		}
+		return e.value(v, "", depth+1)
+	}
+	return nil, fmt.Errorf("%s could not be found", strings.Join(unresolved, ", "))
+}
+
+// resolve looks up a reference, $.subst.* and subst.* in the substitutions, everything else in the document
+func (e *evaluator) resolve(reference string) (interface{}, bool) {
+	reference = strings.TrimPrefix(strings.TrimPrefix(reference, "$"), ".")
+	if reference == SubstPrefix {
+		return e.data, true
+	}
+	if rest, ok := strings.CutPrefix(reference, SubstPrefix+"."); ok {
+		return lookup(e.data, rest)
+	}
+	return lookup(e.document, reference)
+}
+
+// lookup returns the value at a dotted path, list items are selected by index (a.0 or a[0])
+func lookup(v interface{}, path string) (interface{}, bool) {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			item, ok := t[key]
+			if !ok {
+				return nil, false
+			}
+			v = item
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// grab returns the value of a single argument, or a list of the values of multiple arguments
+func grab(args []interface{}) (interface{}, error) {
+	switch len(args) {
+	case 0:
+		return nil, errors.New("grab requires at least one argument")
+	case 1:
+		return args[0], nil
+	}
+	var result []interface{}
+	for _, arg := range args {
+		if list, ok := arg.([]interface{}); ok {
+			result = append(result, list...)
+		} else {
+			result = append(result, arg)
+		}
+	}
+	return result, nil
+}
+
+// concat concatenates scalars into a string, or lists into a list
+func concat(args []interface{}) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, errors.New("concat requires at least two arguments")
+	}
+	if _, ok := args[0].([]interface{}); ok {
+		var result []interface{}
+		for _, arg := range args {
+			list, ok := arg.([]interface{})
+			if !ok {
+				return nil, errors.New("concat can not combine lists and scalars")
+			}
+			result = append(result, list...)
+		}
+		return result, nil
+	}
+	var b strings.Builder
+	for _, arg := range args {
+		s, err := scalar(arg)
+		if err != nil {
+			return nil, fmt.Errorf("concat: %w", err)
+		}
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+// stringify returns the YAML representation of a value
+func stringify(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, errors.New("stringify requires exactly one argument")
+	}
+	switch args[0].(type) {
+	case map[string]interface{}, []interface{}:
+		var out bytes.Buffer
+		encoder := yaml.NewEncoder(&out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(args[0]); err != nil {
+			return nil, err
+		}
+		return out.String(), encoder.Close()
+	}
+	return scalar(args[0])
+}
+
+// joinValues joins lists and scalars with the separator given as first argument
+func joinValues(args []interface{}) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, errors.New("join requires a separator and at least one list")
+	}
+	separator, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("join separator must be a string")
+	}
+	var items []string
+	for _, arg := range args[1:] {
+		values, ok := arg.([]interface{})
+		if !ok {
+			values = []interface{}{arg}
+		}
+		for _, v := range values {
+			s, err := scalar(v)
+			if err != nil {
+				return nil, fmt.Errorf("join: %w", err)
+			}
+			items = append(items, s)
+		}
+	}
+	return strings.Join(items, separator), nil
+}
+
+// scalar formats a scalar value as string
+func scalar(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("%T is not a scalar", v)
+	}
+	return fmt.Sprint(v), nil
+}
+
+func isMergeOperator(value string) bool {
+	if !IsOperator(value) {
+		return false
+	}
+	fields := strings.Fields(operatorPattern.FindStringSubmatch(strings.TrimSpace(value))[1])
+	if len(fields) == 0 {
+		return false
+	}
+	for _, op := range mergeOperators {
+		if fields[0] == op {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,94 @@
+package spruce
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var data = map[string]interface{}{
+	"cluster": map[string]interface{}{
+		"name":     "cluster-01",
+		"replicas": 3,
+		"zones":    []interface{}{"a", "b"},
+	},
+	"settings": map[string]interface{}{
+		"proxy": map[string]interface{}{"host": "proxy.local", "port": 3128},
+	},
+}
+
+func TestEvaluate(t *testing.T) {
+	content := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  cluster: (( grab $.subst.cluster.name ))
+  host: "(( grab $.subst.settings.proxy.host ))"
+  missing: (( grab $.subst.settings.missing || $.subst.settings.other || "default" ))
+  url: (( concat "http://" $.subst.settings.proxy.host ":" $.subst.settings.proxy.port ))
+  zones: (( join "," $.subst.cluster.zones ))
+  proxy: (( stringify $.subst.settings.proxy ))
+  self: (( grab metadata.name ))
+spec:
+  replicas: (( grab $.subst.cluster.replicas ))
+  values: ["default", (( grab $.subst.cluster.name ))]
+  zones:
+  - (( append ))
+  - (( grab $.subst.cluster.zones.0 ))
+`)
+
+	out, err := Evaluate(content, data)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  cluster: cluster-01
+  host: "proxy.local"
+  missing: default
+  url: http://proxy.local:3128
+  zones: a,b
+  proxy: |
+    host: proxy.local
+    port: 3128
+  self: app
+spec:
+  replicas: 3
+  values: ["default", cluster-01]
+  zones:
+  - a
+`, string(out))
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	_, err := Evaluate([]byte("a: (( grab $.subst.nope ))\n"), data)
+	assert.EqualError(t, err, "(( grab $.subst.nope )): $.subst.nope could not be found")
+
+	_, err = Evaluate([]byte("a: (( vault \"secret/path:key\" ))\n"), data)
+	assert.ErrorContains(t, err, `unsupported spruce operator "vault"`)
+
+	_, err = Evaluate([]byte("a: (( grab $.subst.nope || ))\n"), data)
+	assert.ErrorContains(t, err, "missing operand after ||")
+}
+
+func TestEvaluateData(t *testing.T) {
+	out, err := EvaluateData(map[string]interface{}{
+		"name":  "app",
+		"image": "(( concat \"registry/\" $.subst.name \":1.0\" ))",
+		"ref":   "(( grab image ))",
+		"cycle": "(( grab cycle ))",
+	})
+	assert.ErrorContains(t, err, "cycle: (( grab cycle ))")
+	assert.Nil(t, out)
+
+	out, err = EvaluateData(map[string]interface{}{
+		"name":  "app",
+		"image": "(( concat \"registry/\" $.subst.name \":1.0\" ))",
+		"ref":   "(( grab image ))",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "registry/app:1.0", out["image"])
+	assert.Equal(t, "registry/app:1.0", out["ref"])
+}
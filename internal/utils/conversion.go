@@ -2,45 +2,102 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"gopkg.in/yaml.v3"
 )
 
-// UnmarshalJSONorYAMLToInterface unmarshals data to interface map
-func UnmarshalJSONorYAMLToInterface(data []byte, result *map[interface{}]interface{}) error {
-	var tmp map[string]interface{}
-	err := json.Unmarshal(data, &tmp)
-	if err != nil {
-		err = yaml.Unmarshal(data, &tmp)
+const (
+	// JSONModeList prints all documents as items of a Kubernetes v1/List
+	JSONModeList = "list"
+	// JSONModeArray prints all documents as a JSON array
+	JSONModeArray = "array"
+	// JSONModeNDJSON prints each document as a single line (newline-delimited JSON)
+	JSONModeNDJSON = "ndjson"
+)
+
+// JSONModes are the supported modes for JSON output
+var JSONModes = []string{JSONModeList, JSONModeArray, JSONModeNDJSON}
+
+// UnmarshalYAMLDocuments unmarshals all documents of a (multi-document) YAML or JSON stream.
+// Empty documents are skipped and all maps are converted to map[string]interface{}.
+func UnmarshalYAMLDocuments(data []byte) ([]interface{}, error) {
+	var documents []interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
+		stringifyTimestamps(&node)
+
+		var document interface{}
+		if err := node.Decode(&document); err != nil {
+			return nil, err
+		}
+		if document == nil {
+			continue
+		}
+		documents = append(documents, mapify(document))
 	}
-	*result = ToInterface(tmp)
-	return nil
+	return documents, nil
 }
 
-// ToInterface converts a map[string]interface{} to a map[interface{}]interface{}.
-func ToInterface(inputMap map[string]interface{}) map[interface{}]interface{} {
-	var convertedMap = make(map[interface{}]interface{}, len(inputMap))
-	for key, value := range inputMap {
-		convertedMap[key] = value
+// stringifyTimestamps keeps unquoted timestamps as written, instead of decoding them to time.Time
+func stringifyTimestamps(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!timestamp" {
+		node.Tag = "!!str"
+	}
+	for _, child := range node.Content {
+		stringifyTimestamps(child)
 	}
-	return convertedMap
 }
 
-// PrintJSON prints a map as JSON
-func PrintJSON(data map[interface{}]interface{}) error {
-	// Convert to map[string]interface{} for JSON marshaling
-	stringMap := mapify(data)
-	j, err := json.MarshalIndent(stringMap, "", "  ")
+// PrintJSONDocuments prints documents as JSON in the given mode (list, array or ndjson)
+func PrintJSONDocuments(documents []interface{}, mode string) error {
+	if documents == nil {
+		documents = []interface{}{}
+	}
+
+	var out []byte
+	var err error
+	switch mode {
+	case JSONModeList:
+		out, err = json.MarshalIndent(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      documents,
+		}, "", "  ")
+	case JSONModeArray:
+		out, err = json.MarshalIndent(documents, "", "  ")
+	case JSONModeNDJSON:
+		var lines bytes.Buffer
+		for _, document := range documents {
+			line, err := json.Marshal(document)
+			if err != nil {
+				return err
+			}
+			lines.Write(line)
+			lines.WriteByte('\n')
+		}
+		_, err = os.Stdout.Write(lines.Bytes())
+		return err
+	default:
+		return fmt.Errorf("invalid json mode %q, must be one of %v", mode, JSONModes)
+	}
 	if err != nil {
 		return err
 	}
-	fmt.Println(string(j))
+	fmt.Println(string(out))
 	return nil
 }
 
@@ -59,41 +116,64 @@ func PrintYAMLBytes(data []byte) error {
 	return nil
 }
 
-// mapify converts map[interface{}]interface{} to map[string]interface{}
-func mapify(input map[interface{}]interface{}) map[string]interface{} {
-	output := make(map[string]interface{}, len(input))
-	for k, v := range input {
-		switch vv := v.(type) {
-		case map[interface{}]interface{}:
-			output[k.(string)] = mapify(vv)
-		default:
-			output[k.(string)] = vv
+// mapify recursively converts map[interface{}]interface{} (as decoded for non-string keys)
+// to map[string]interface{}, so the value can be marshaled as JSON
+func mapify(input interface{}) interface{} {
+	switch v := input.(type) {
+	case map[interface{}]interface{}:
+		output := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			output[fmt.Sprint(key)] = mapify(value)
+		}
+		return output
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			output[key] = mapify(value)
 		}
+		return output
+	case []interface{}:
+		output := make([]interface{}, len(v))
+		for i, value := range v {
+			output[i] = mapify(value)
+		}
+		return output
+	default:
+		return v
 	}
-	return output
 }
 
 // DeepMerge recursively merges src into dst
 // If a key exists in both maps and both values are maps, it recursively merges them
 // Otherwise, src values override dst values
+// Maps of src are copied, so later merges into dst never modify src
 func DeepMerge(dst, src map[string]interface{}) map[string]interface{} {
 	for key, srcVal := range src {
 		if dstVal, exists := dst[key]; exists {
 			// Both dst and src have this key
 			srcMap, srcIsMap := srcVal.(map[string]interface{})
 			dstMap, dstIsMap := dstVal.(map[string]interface{})
-			
+
 			if srcIsMap && dstIsMap {
 				// Both are maps, merge recursively
 				dst[key] = DeepMerge(dstMap, srcMap)
 			} else {
 				// Not both maps, src overrides dst
-				dst[key] = srcVal
+				dst[key] = copyMaps(srcVal)
 			}
 		} else {
 			// Key only in src, add it
-			dst[key] = srcVal
+			dst[key] = copyMaps(srcVal)
 		}
 	}
 	return dst
 }
+
+// copyMaps returns a deep copy of value, if it is a map
+func copyMaps(value interface{}) interface{} {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	return DeepMerge(make(map[string]interface{}, len(m)), m)
+}
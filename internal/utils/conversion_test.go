@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalYAMLDocuments(t *testing.T) {
+	data := []byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+data:
+  date: 2024-01-01
+---
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+  annotations:
+    nested:
+      - 1: one
+        two: [a, b]
+`)
+
+	documents, err := UnmarshalYAMLDocuments(data)
+	assert.NoError(t, err)
+	assert.Len(t, documents, 2, "Expected empty documents to be skipped")
+
+	first := documents[0].(map[string]interface{})
+	assert.Equal(t, "2024-01-01", first["data"].(map[string]interface{})["date"], "Expected timestamps to stay strings")
+
+	second := documents[1].(map[string]interface{})
+	nested := second["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})["nested"]
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"1": "one", "two": []interface{}{"a", "b"}},
+	}, nested, "Expected nested non-string keys to be converted")
+}
+
+func TestUnmarshalYAMLDocumentsMalformed(t *testing.T) {
+	_, err := UnmarshalYAMLDocuments([]byte("key: [unclosed"))
+	assert.Error(t, err)
+}
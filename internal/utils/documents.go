@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+var (
+	// documentSeparator matches the separator lines of a YAML stream
+	documentSeparator = regexp.MustCompile(`^---\s*(#.*)?$`)
+	// yamlErrorLine matches the line of a YAML parser error
+	yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+)
+
+// DocumentError is a malformed document of a YAML stream
+type DocumentError struct {
+	// Index of the document in the stream (starting at 0)
+	Index int
+	// Line of the error in the stream (starting at 1), 0 if unknown
+	Line int
+	// Snippet are the lines around the error
+	Snippet string
+	Err     error
+}
+
+func (e *DocumentError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("malformed document %d: %v", e.Index, e.Err)
+	}
+	return fmt.Sprintf("malformed document %d (line %d): %v\n%s", e.Index, e.Line, e.Err, e.Snippet)
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// NormalizeYAML parses a YAML stream and serializes it in the normalized kustomize format.
+// Empty documents are dropped, all malformed documents are reported.
+func NormalizeYAML(data []byte) ([]byte, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	var out bytes.Buffer
+	var errs []error
+	index, start := 0, 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && !documentSeparator.MatchString(lines[i]) {
+			continue
+		}
+		if i > start || i == len(lines) {
+			document, err := normalizeDocument(strings.Join(lines[start:i], "\n"))
+			if err != nil {
+				errs = append(errs, documentError(index, start, lines, err))
+			} else if document != "" {
+				if out.Len() > 0 {
+					out.WriteString("---\n")
+				}
+				out.WriteString(document)
+			}
+			index++
+		}
+		start = i + 1
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return out.Bytes(), nil
+}
+
+// normalizeDocument serializes a single document, empty documents result in an empty string
+func normalizeDocument(document string) (string, error) {
+	node := &yaml.Node{}
+	err := yaml.NewDecoder(strings.NewReader(document)).Decode(node)
+	if errors.Is(err, io.EOF) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if yaml.IsYNodeEmptyDoc(node) || len(node.Content) == 0 {
+		return "", nil
+	}
+
+	content := node.Content[0]
+	if content.Kind == yaml.ScalarNode && content.Tag == yaml.NodeTagNull {
+		return "", nil
+	}
+	if content.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("yaml: line %d: document is not a mapping", content.Line)
+	}
+	blockStyle(content)
+	return yaml.String(content)
+}
+
+// blockStyle resets flow mappings and sequences to block style, empty collections are still written as {} and []
+func blockStyle(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style = 0
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// documentError locates the error of the document starting at line start
+func documentError(index int, start int, lines []string, err error) error {
+	documentErr := &DocumentError{Index: index, Err: err}
+
+	match := yamlErrorLine.FindStringSubmatch(err.Error())
+	if match == nil {
+		return documentErr
+	}
+	line, _ := strconv.Atoi(match[1])
+	documentErr.Line = start + line
+	documentErr.Err = errors.New(match[2])
+
+	// Show two lines before and after the error
+	var snippet strings.Builder
+	for i := max(documentErr.Line-3, start); i < min(documentErr.Line+2, len(lines)); i++ {
+		marker := " "
+		if i+1 == documentErr.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(&snippet, "  %s %4d | %s\n", marker, i+1, lines[i])
+	}
+	documentErr.Snippet = strings.TrimSuffix(snippet.String(), "\n")
+	return documentErr
+}
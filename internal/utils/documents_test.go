@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeYAML(t *testing.T) {
+	data := []byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+    name: first
+data:
+    list:
+        - a
+---
+
+---
+# only a comment
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: second, labels: {}}
+data: {list: [a, b], empty: []}
+`)
+
+	normalized, err := NormalizeYAML(data)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+data:
+  list:
+  - a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+  labels: {}
+data:
+  list:
+  - a
+  - b
+  empty: []
+`, string(normalized))
+
+	normalized, err = NormalizeYAML([]byte("---\n\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, normalized, "Expected empty documents to be dropped")
+}
+
+func TestNormalizeYAMLMalformed(t *testing.T) {
+	data := []byte(`apiVersion: v1
+kind: ConfigMap
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: broken
+ data:
+  key: value
+---
+just a string
+`)
+
+	_, err := NormalizeYAML(data)
+	var documentErr *DocumentError
+	assert.ErrorAs(t, err, &documentErr)
+	assert.Equal(t, 1, documentErr.Index)
+	assert.Equal(t, 7, documentErr.Line)
+	assert.Contains(t, documentErr.Snippet, "     8 |  data:")
+	assert.ErrorContains(t, err, "malformed document 2 (line 11): document is not a mapping")
+}
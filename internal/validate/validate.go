@@ -0,0 +1,342 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubelize/subst/internal/utils"
+	"github.com/rs/zerolog/log"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+)
+
+const (
+	// gvkExtension lists the group, version and kind of a schema
+	gvkExtension = "x-kubernetes-group-version-kind"
+	// definitionsPrefix is the prefix of references to definitions
+	definitionsPrefix = "#/definitions/"
+)
+
+// ErrNoSchema is returned when there is no schema for the kind of a resource
+var ErrNoSchema = errors.New("no schema found")
+
+// TypeMeta identifies the schema of a resource
+type TypeMeta struct {
+	APIVersion string
+	Kind       string
+}
+
+// Validator validates resources against the bundled Kubernetes OpenAPI schemas,
+// additional OpenAPI documents, JSON schemas and CRDs. No cluster access is required.
+type Validator struct {
+	// definitions referenced by schemas (#/definitions/<name>)
+	definitions spec.Definitions
+	// schemas by resource type, references are not yet expanded
+	types map[TypeMeta]*spec.Schema
+	// expanded schemas by resource type
+	expanded map[TypeMeta]*spec.Schema
+}
+
+// NewValidator initializes a validator with the bundled Kubernetes schemas and the schemas found in paths.
+// Paths are files or directories with OpenAPI documents (definitions), JSON schemas (with x-kubernetes-group-version-kind)
+// or CustomResourceDefinitions, in YAML or JSON.
+func NewValidator(paths ...string) (*Validator, error) {
+	v := &Validator{
+		definitions: spec.Definitions{},
+		types:       map[TypeMeta]*spec.Schema{},
+		expanded:    map[TypeMeta]*spec.Schema{},
+	}
+	v.addDefinitions(openapi.Schema().Definitions)
+
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !isSchemaFile(file) {
+				return nil
+			}
+			if err := v.addFile(file); err != nil {
+				return fmt.Errorf("failed to load schema %s: %w", file, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+func isSchemaFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// addFile adds all OpenAPI documents, JSON schemas and CRDs in file
+func (v *Validator) addFile(file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	documents, err := utils.UnmarshalYAMLDocuments(content)
+	if err != nil {
+		return err
+	}
+
+	for _, document := range documents {
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch {
+		case IsCRD(object):
+			err = v.AddCRD(object)
+		case object["definitions"] != nil:
+			var swagger spec.Swagger
+			if err = convert(object, &swagger); err == nil {
+				v.addDefinitions(swagger.Definitions)
+			}
+		case object[gvkExtension] != nil:
+			var schema spec.Schema
+			if err = convert(object, &schema); err == nil {
+				v.addDefinitions(spec.Definitions{file: schema})
+			}
+		default:
+			log.Debug().Msgf("Skipping document without schema in %s", file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDefinitions adds definitions and indexes them by the resource types they describe
+func (v *Validator) addDefinitions(definitions spec.Definitions) {
+	for name, definition := range definitions {
+		definition := definition
+		v.definitions[name] = definition
+
+		gvks, ok := definition.Extensions[gvkExtension].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, gvk := range gvks {
+			m, ok := gvk.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			group, _ := m["group"].(string)
+			version, _ := m["version"].(string)
+			kind, _ := m["kind"].(string)
+			v.addType(TypeMeta{APIVersion: apiVersion(group, version), Kind: kind}, &definition)
+		}
+	}
+}
+
+func (v *Validator) addType(t TypeMeta, schema *spec.Schema) {
+	v.types[t] = schema
+	delete(v.expanded, t)
+}
+
+// IsCRD reports whether object is a CustomResourceDefinition
+func IsCRD(object map[string]interface{}) bool {
+	return object["kind"] == "CustomResourceDefinition" && strings.HasPrefix(fmt.Sprint(object["apiVersion"]), "apiextensions.k8s.io/")
+}
+
+// AddCRD adds the schemas of all versions of a CustomResourceDefinition
+func (v *Validator) AddCRD(object map[string]interface{}) error {
+	var crd struct {
+		Spec struct {
+			Group string `json:"group"`
+			Names struct {
+				Kind string `json:"kind"`
+			} `json:"names"`
+			Versions []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					OpenAPIV3Schema *spec.Schema `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if err := convert(object, &crd); err != nil {
+		return fmt.Errorf("invalid CustomResourceDefinition: %w", err)
+	}
+
+	for _, version := range crd.Spec.Versions {
+		if version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		v.addType(TypeMeta{
+			APIVersion: apiVersion(crd.Spec.Group, version.Name),
+			Kind:       crd.Spec.Names.Kind,
+		}, version.Schema.OpenAPIV3Schema)
+	}
+	return nil
+}
+
+// Validate validates object against the schema of its kind.
+// Violations are returned as ViolationError, ErrNoSchema if there is no schema for the kind.
+func (v *Validator) Validate(object map[string]interface{}) error {
+	t := TypeMeta{}
+	t.APIVersion, _ = object["apiVersion"].(string)
+	t.Kind, _ = object["kind"].(string)
+
+	schema, err := v.schema(t)
+	if err != nil {
+		return err
+	}
+
+	result := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(object)
+	if !result.HasErrors() {
+		return nil
+	}
+	violations := make([]string, 0, len(result.Errors))
+	for _, err := range result.Errors {
+		violations = append(violations, strings.TrimPrefix(err.Error(), "."))
+	}
+	return &ViolationError{Violations: violations}
+}
+
+// ViolationError lists all schema violations of a resource
+type ViolationError struct {
+	Violations []string
+}
+
+func (e *ViolationError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// schema returns the expanded schema for a resource type
+func (v *Validator) schema(t TypeMeta) (*spec.Schema, error) {
+	if schema, ok := v.expanded[t]; ok {
+		return schema, nil
+	}
+	schema, ok := v.types[t]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s %s", ErrNoSchema, t.APIVersion, t.Kind)
+	}
+
+	expanded, err := v.expand(schema, map[string]bool{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema for %s %s: %w", t.APIVersion, t.Kind, err)
+	}
+	v.expanded[t] = expanded
+	return expanded, nil
+}
+
+// expand returns a copy of schema with all references to definitions replaced by the definition.
+// Recursive references (eg. JSONSchemaProps) accept any value.
+func (v *Validator) expand(schema *spec.Schema, visiting map[string]bool) (*spec.Schema, error) {
+	if schema == nil {
+		return nil, nil
+	}
+
+	if ref := schema.Ref.String(); ref != "" {
+		name := strings.TrimPrefix(ref, definitionsPrefix)
+		definition, ok := v.definitions[name]
+		if !ok || name == ref {
+			return nil, fmt.Errorf("unresolved reference %s", ref)
+		}
+		if visiting[name] {
+			return &spec.Schema{}, nil
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+		return v.expand(lenient(name, &definition), visiting)
+	}
+
+	out := *schema
+	var err error
+	if schema.Properties != nil {
+		out.Properties = make(map[string]spec.Schema, len(schema.Properties))
+		for key, property := range schema.Properties {
+			expanded, err := v.expand(&property, visiting)
+			if err != nil {
+				return nil, err
+			}
+			out.Properties[key] = *expanded
+		}
+	}
+	if schema.Items != nil {
+		out.Items = &spec.SchemaOrArray{}
+		if out.Items.Schema, err = v.expand(schema.Items.Schema, visiting); err != nil {
+			return nil, err
+		}
+		for _, item := range schema.Items.Schemas {
+			expanded, err := v.expand(&item, visiting)
+			if err != nil {
+				return nil, err
+			}
+			out.Items.Schemas = append(out.Items.Schemas, *expanded)
+		}
+	}
+	if schema.AdditionalProperties != nil {
+		out.AdditionalProperties = &spec.SchemaOrBool{Allows: schema.AdditionalProperties.Allows}
+		if out.AdditionalProperties.Schema, err = v.expand(schema.AdditionalProperties.Schema, visiting); err != nil {
+			return nil, err
+		}
+	}
+	for _, list := range []*[]spec.Schema{&out.AllOf, &out.AnyOf, &out.OneOf} {
+		expanded := make([]spec.Schema, 0, len(*list))
+		for _, item := range *list {
+			e, err := v.expand(&item, visiting)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, *e)
+		}
+		if len(expanded) > 0 {
+			*list = expanded
+		}
+	}
+	if out.Not, err = v.expand(schema.Not, visiting); err != nil {
+		return nil, err
+	}
+
+	return lenient("", &out), nil
+}
+
+// lenient accepts numbers for quantities and int-or-string values, as the API server does.
+// CRD fields with x-kubernetes-int-or-string have no type and accept any value.
+func lenient(name string, schema *spec.Schema) *spec.Schema {
+	switch {
+	case name == "io.k8s.apimachinery.pkg.api.resource.Quantity":
+		out := *schema
+		out.Type = spec.StringOrArray{"string", "number"}
+		return &out
+	case schema.Format == "int-or-string":
+		out := *schema
+		out.Type = spec.StringOrArray{"string", "integer"}
+		return &out
+	}
+	return schema
+}
+
+func apiVersion(group, version string) string {
+	if group == "" {
+		return version
+	}
+	return group + "/" + version
+}
+
+// convert converts a decoded document into a typed value by its JSON representation
+func convert(in interface{}, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func deployment(replicas interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "app"},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "app"}},
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":      "app",
+							"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": 1, "memory": "1Gi"}},
+							"ports":     []interface{}{map[string]interface{}{"containerPort": 8080}},
+							"livenessProbe": map[string]interface{}{
+								"httpGet": map[string]interface{}{"port": "http"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v, err := NewValidator()
+	assert.NoError(t, err)
+
+	assert.NoError(t, v.Validate(deployment(3)))
+
+	err = v.Validate(deployment("3"))
+	assert.EqualError(t, err, `spec.replicas in body must be of type integer: "string"`)
+
+	err = v.Validate(map[string]interface{}{"apiVersion": "example.com/v1", "kind": "Widget"})
+	assert.ErrorIs(t, err, ErrNoSchema)
+}
+
+const crd = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+    - name: v1
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [color]
+              properties:
+                size:
+                  type: integer
+                color:
+                  type: string
+`
+
+func TestValidateCRD(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "widget.yaml"), []byte(crd), 0o644))
+
+	v, err := NewValidator(dir)
+	assert.NoError(t, err)
+
+	err = v.Validate(map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"spec":       map[string]interface{}{"size": "big"},
+	})
+	var violations *ViolationError
+	assert.ErrorAs(t, err, &violations)
+	assert.ElementsMatch(t, []string{
+		`spec.size in body must be of type integer: "string"`,
+		"spec.color in body is required",
+	}, violations.Violations)
+}
@@ -0,0 +1,35 @@
+package wrapper
+
+import (
+	"os"
+
+	"github.com/hairyhenderson/gomplate/v4/conv"
+)
+
+// envFuncs replaces the gomplate env namespace (env.Getenv, env.ExpandEnv and getenv),
+// so templates can only read the allowlisted environment
+type envFuncs struct {
+	env map[string]string
+}
+
+// Getenv returns the value of an allowlisted variable, or the default if it is not set or empty
+func (e envFuncs) Getenv(key interface{}, def ...string) string {
+	if value := e.env[conv.ToString(key)]; value != "" || len(def) == 0 {
+		return value
+	}
+	return def[0]
+}
+
+// ExpandEnv replaces $VAR and ${VAR} with the values of allowlisted variables
+func (e envFuncs) ExpandEnv(s interface{}) string {
+	return os.Expand(conv.ToString(s), func(key string) string {
+		return e.env[key]
+	})
+}
+
+// addEnvFuncs registers the env namespace for the environment
+func addEnvFuncs(funcs map[string]interface{}, env map[string]string) {
+	ns := envFuncs{env: env}
+	funcs["env"] = func() interface{} { return ns }
+	funcs["getenv"] = ns.Getenv
+}
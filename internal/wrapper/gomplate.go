@@ -2,65 +2,59 @@ package wrapper
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"os"
-	"os/exec"
+	"text/template"
 
-	"gopkg.in/yaml.v3"
+	"github.com/hairyhenderson/gomplate/v4"
 )
 
-// ProcessGomplateTemplate processes templates using the gomplate binary.
-// Gomplate must be installed and available in PATH.
-func ProcessGomplateTemplate(templateContent []byte, envData map[string]interface{}, envRegex string) ([]byte, error) {
-	// Verify gomplate is available
-	if _, err := exec.LookPath("gomplate"); err != nil {
-		return nil, fmt.Errorf("gomplate binary not found in PATH. Please install from: https://github.com/hairyhenderson/gomplate#installation")
-	}
-
-	return processWithGomplateBinary(templateContent, envData)
+// Options configure the template processing
+type Options struct {
+	// Strict fails on references to variables, which are not defined in the substitutions
+	Strict bool
+	// LeftDelim and RightDelim are the template delimiters (default: {{ and }})
+	LeftDelim  string
+	RightDelim string
+	// Env is the environment available to the env functions (eg. getenv), other variables are not visible
+	Env map[string]string
+	// Sandbox restricts the functions, if set
+	Sandbox *Sandbox
 }
 
-// processWithGomplateBinary uses the gomplate command-line tool
-func processWithGomplateBinary(templateContent []byte, envData map[string]interface{}) ([]byte, error) {
-	// Create a temporary file with the context data
-	contextData, err := yaml.Marshal(envData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal context data: %w", err)
+// ProcessGomplateTemplate renders templateContent in-process with the gomplate function set.
+// The substitution data is the template context, so values are accessible as {{ .path.to.value }}.
+func ProcessGomplateTemplate(templateContent []byte, envData map[string]interface{}, opts Options) ([]byte, error) {
+	tmpl := newTemplate("subst", opts).Delims(opts.LeftDelim, opts.RightDelim)
+	if _, err := tmpl.Parse(string(templateContent)); err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	// Create temp files for context data
-	tmpFile, err := os.CreateTemp("", "subst-context-*.yaml")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	if opts.Strict {
+		// References with default are optional
+		markOptional(tmpl.Tree.Root)
+		// Report all undefined references at once, execution would stop at the first one
+		if undefined := undefinedReferences(tmpl, envData); len(undefined) > 0 {
+			return nil, &UndefinedError{References: undefined}
+		}
+		tmpl.Option("missingkey=error")
 	}
-	defer os.Remove(tmpFile.Name())
-	defer tmpFile.Close()
 
-	_, err = tmpFile.Write(contextData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to write context data: %w", err)
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, envData); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
 	}
-	tmpFile.Close()
-
-	// Use gomplate with context file
-	cmd := exec.Command("gomplate", "--context", fmt.Sprintf(".=%s", tmpFile.Name()))
-
-	// Set basic environment for gomplate (keeping existing env vars)
-	cmd.Env = os.Environ()
 
-	// Pipe template content to gomplate
-	cmd.Stdin = bytes.NewReader(templateContent)
-
-	// Capture output
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return out.Bytes(), nil
+}
 
-	// Execute gomplate
-	err = cmd.Run()
-	if err != nil {
-		return nil, fmt.Errorf("gomplate execution failed: %w, stderr: %s", err, stderr.String())
+// newTemplate creates an empty template with all gomplate functions registered
+func newTemplate(name string, opts Options) *template.Template {
+	funcs := gomplate.CreateFuncs(context.Background())
+	addEnvFuncs(funcs, opts.Env)
+	funcs[optionalFunc] = optional
+	if opts.Sandbox != nil {
+		opts.Sandbox.apply(funcs)
 	}
-
-	return stdout.Bytes(), nil
+	return template.New(name).Funcs(funcs)
 }
@@ -0,0 +1,100 @@
+package wrapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessGomplateTemplate(t *testing.T) {
+	data := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"app": map[string]interface{}{"name": "my-app"},
+		},
+	}
+
+	out, err := ProcessGomplateTemplate([]byte(`name: "{{ .settings.app.name | strings.ToUpper }}"`), data, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, `name: "MY-APP"`, string(out))
+}
+
+func TestProcessGomplateTemplateParseError(t *testing.T) {
+	_, err := ProcessGomplateTemplate([]byte(`name: "{{ .settings.app.name "`), nil, Options{})
+	assert.Error(t, err)
+}
+
+func TestProcessGomplateTemplateStrict(t *testing.T) {
+	data := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"app": map[string]interface{}{"name": "my-app"},
+		},
+		"list": []interface{}{"a", "b"},
+	}
+	content := []byte(`name: "{{ .setings.app.name }}"
+version: "{{ .settings.app.version }}"
+{{- with .settings.app }}
+app: "{{ .name }}{{ .namespace }}"
+{{- end }}
+{{- range .list }}
+item: "{{ . }}{{ $.settings.missing }}"
+{{- end }}`)
+
+	out, err := ProcessGomplateTemplate(content, data, Options{})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "<no value>")
+
+	_, err = ProcessGomplateTemplate(content, data, Options{Strict: true})
+	var undefined *UndefinedError
+	assert.ErrorAs(t, err, &undefined)
+	assert.Equal(t, []string{
+		".setings.app.name (line 1)",
+		".settings.app.version (line 2)",
+		".namespace (line 4)",
+		"$.settings.missing (line 7)",
+	}, undefined.References)
+}
+
+func TestProcessGomplateTemplateStrictRuntime(t *testing.T) {
+	data := map[string]interface{}{
+		"list": []interface{}{map[string]interface{}{"name": "a"}},
+	}
+
+	_, err := ProcessGomplateTemplate([]byte(`{{ range .list }}{{ .missing }}{{ end }}`), data, Options{Strict: true})
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestProcessGomplateTemplateStrictOptional(t *testing.T) {
+	data := map[string]interface{}{
+		"settings": map[string]interface{}{"name": "app"},
+	}
+
+	out, err := ProcessGomplateTemplate([]byte(`{{ if has .settings "x" }}{{ .settings.x }}{{ else }}none{{ end }}`), data, Options{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "none", string(out))
+
+	out, err = ProcessGomplateTemplate([]byte(`{{ .settings.x | default "d" }} {{ $.settings.proxy.host | default "localhost" }} {{ default "x" .settings.name }} {{ .settings.name | default "d" }}`), data, Options{Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "d localhost app app", string(out))
+
+	_, err = ProcessGomplateTemplate([]byte(`{{ if has .settings "name" }}{{ .settings.x }}{{ end }}`), data, Options{Strict: true})
+	assert.ErrorContains(t, err, `map has no entry for key "x"`)
+}
+
+func TestProcessGomplateTemplateDelimiters(t *testing.T) {
+	data := map[string]interface{}{"name": "my-app"}
+
+	out, err := ProcessGomplateTemplate([]byte(`name: "[[ .name ]]"
+summary: "{{ $labels.instance }} is down"`), data, Options{LeftDelim: "[[", RightDelim: "]]"})
+	assert.NoError(t, err)
+	assert.Equal(t, `name: "my-app"
+summary: "{{ $labels.instance }} is down"`, string(out))
+}
+
+func TestProcessGomplateTemplateEnv(t *testing.T) {
+	t.Setenv("SUBST_TEST_HIDDEN", "hidden")
+	opts := Options{Env: map[string]string{"ARGOCD_ENV_NAME": "app"}}
+
+	out, err := ProcessGomplateTemplate([]byte(`{{ getenv "ARGOCD_ENV_NAME" }} {{ env.Getenv "SUBST_TEST_HIDDEN" "default" }} {{ env.ExpandEnv "${ARGOCD_ENV_NAME}-$SUBST_TEST_HIDDEN" }}`), nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "app default app-", string(out))
+}
@@ -0,0 +1,156 @@
+package wrapper
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hairyhenderson/gomplate/v4/conv"
+)
+
+// Sandbox restricts the template functions for templates, which are not trusted (eg. of tenants in
+// multi-tenant repositories): files can only be read below Root, network, cloud metadata and
+// environment functions are blocked.
+type Sandbox struct {
+	// Root is the directory files can be read from (eg. the repository)
+	Root string
+	// Dir is the directory relative paths are resolved from
+	Dir string
+}
+
+// blockedFuncs are not available in the sandbox, with the reason
+var blockedFuncs = map[string]string{
+	"net":        "network access is not allowed",
+	"sockaddr":   "network access is not allowed",
+	"aws":        "cloud metadata and network access is not allowed",
+	"ec2meta":    "cloud metadata and network access is not allowed",
+	"ec2dynamic": "cloud metadata and network access is not allowed",
+	"ec2region":  "cloud metadata and network access is not allowed",
+	"ec2tag":     "cloud metadata and network access is not allowed",
+	"ec2tags":    "cloud metadata and network access is not allowed",
+	"gcp":        "cloud metadata and network access is not allowed",
+	"env":        "environment access is not allowed",
+	"getenv":     "environment access is not allowed",
+}
+
+// SandboxError is returned by functions, which are blocked by the sandbox
+type SandboxError struct {
+	Function string
+	Reason   string
+}
+
+func (e *SandboxError) Error() string {
+	return fmt.Sprintf("%s is blocked by the sandbox: %s", e.Function, e.Reason)
+}
+
+// apply replaces the blocked functions and the file namespace
+func (s *Sandbox) apply(funcs map[string]interface{}) {
+	for name, reason := range blockedFuncs {
+		function := &SandboxError{Function: name, Reason: reason}
+		funcs[name] = func(...interface{}) (interface{}, error) {
+			return nil, function
+		}
+	}
+	ns := &sandboxFileFuncs{sandbox: s}
+	funcs["file"] = func() interface{} { return ns }
+}
+
+// sandboxFileFuncs replaces the gomplate file namespace, files can only be read below the sandbox root
+type sandboxFileFuncs struct {
+	sandbox *Sandbox
+}
+
+// Read returns the content of a file
+func (f *sandboxFileFuncs) Read(path interface{}) (string, error) {
+	var content []byte
+	err := f.do("file.Read", path, func(fsys fs.FS, name string) (err error) {
+		content, err = fs.ReadFile(fsys, name)
+		return err
+	})
+	return string(content), err
+}
+
+// Stat returns the file info of a file
+func (f *sandboxFileFuncs) Stat(path interface{}) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := f.do("file.Stat", path, func(fsys fs.FS, name string) (err error) {
+		info, err = fs.Stat(fsys, name)
+		return err
+	})
+	return info, err
+}
+
+// Exists reports whether a file exists
+func (f *sandboxFileFuncs) Exists(path interface{}) bool {
+	_, err := f.Stat(path)
+	return err == nil
+}
+
+// IsDir reports whether a file is a directory
+func (f *sandboxFileFuncs) IsDir(path interface{}) bool {
+	info, err := f.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ReadDir returns the names of the entries of a directory
+func (f *sandboxFileFuncs) ReadDir(path interface{}) ([]string, error) {
+	var names []string
+	err := f.do("file.ReadDir", path, func(fsys fs.FS, name string) error {
+		entries, err := fs.ReadDir(fsys, name)
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return err
+	})
+	return names, err
+}
+
+// Walk returns all files and directories below path
+func (f *sandboxFileFuncs) Walk(path interface{}) ([]string, error) {
+	files := []string{}
+	err := f.do("file.Walk", path, func(fsys fs.FS, name string) error {
+		return fs.WalkDir(fsys, name, func(sub string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(filepath.FromSlash(name), filepath.FromSlash(sub))
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.Join(conv.ToString(path), rel))
+			return nil
+		})
+	})
+	return files, err
+}
+
+// Write is blocked, templates must not modify files
+func (f *sandboxFileFuncs) Write(_ interface{}, _ interface{}) (string, error) {
+	return "", &SandboxError{Function: "file.Write", Reason: "writing files is not allowed"}
+}
+
+// do runs fn with the file system of the sandbox root and the path relative to it
+func (f *sandboxFileFuncs) do(function string, path interface{}, fn func(fsys fs.FS, name string) error) error {
+	file := conv.ToString(path)
+	abs := file
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(f.sandbox.Dir, abs)
+	}
+	rel, err := filepath.Rel(f.sandbox.Root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &SandboxError{Function: function, Reason: fmt.Sprintf("%s is outside of %s", file, f.sandbox.Root)}
+	}
+
+	// The root prevents escaping the sandbox with symlinks
+	root, err := os.OpenRoot(f.sandbox.Root)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	if err := fn(root.FS(), filepath.ToSlash(rel)); err != nil {
+		return fmt.Errorf("%s %s: %w", function, file, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package wrapper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandbox(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "clusters", "cluster-01")
+	assert.NoError(t, os.MkdirAll(dir, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "ca.crt"), []byte("CERT"), 0o644))
+	outside := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(outside, []byte("SECRET"), 0o644))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+
+	opts := Options{Sandbox: &Sandbox{Root: root, Dir: dir}, Env: map[string]string{"ARGOCD_ENV_NAME": "app"}}
+
+	out, err := ProcessGomplateTemplate([]byte(`{{ file.Read "../../ca.crt" }} {{ file.Exists "link" }} {{ file.ReadDir "../.." }}`), nil, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "CERT false [ca.crt clusters]", string(out))
+
+	for template, message := range map[string]string{
+		`{{ file.Read "/etc/passwd" }}`:      "file.Read is blocked by the sandbox: /etc/passwd is outside of " + root,
+		`{{ file.Read "../../../x" }}`:       "file.Read is blocked by the sandbox: ../../../x is outside of " + root,
+		`{{ file.Read "link" }}`:             "file.Read link:",
+		`{{ file.Write "x" "y" }}`:           "file.Write is blocked by the sandbox: writing files is not allowed",
+		`{{ net.LookupIP "example.com" }}`:   "net is blocked by the sandbox: network access is not allowed",
+		`{{ ec2meta "instance-id" }}`:        "ec2meta is blocked by the sandbox: cloud metadata and network access is not allowed",
+		`{{ getenv "ARGOCD_ENV_NAME" }}`:     "getenv is blocked by the sandbox: environment access is not allowed",
+		`{{ env.Getenv "ARGOCD_ENV_NAME" }}`: "env is blocked by the sandbox: environment access is not allowed",
+		`{{ sockaddr.GetPrivateIP }}`:        "sockaddr is blocked by the sandbox",
+		`{{ aws.EC2Meta "instance-id" }}`:    "aws is blocked by the sandbox",
+		`{{ gcp.Meta "instance/hostname" }}`: "gcp is blocked by the sandbox",
+	} {
+		_, err := ProcessGomplateTemplate([]byte(template), nil, opts)
+		assert.ErrorContains(t, err, message, template)
+	}
+}
@@ -0,0 +1,250 @@
+package wrapper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// UndefinedError lists all references to variables which are not defined in the substitutions
+type UndefinedError struct {
+	References []string
+}
+
+func (e *UndefinedError) Error() string {
+	return fmt.Sprintf("undefined variable(s): %s", strings.Join(e.References, ", "))
+}
+
+// undefinedReferences statically collects all field references (.a.b, $.a.b) of the template,
+// which can not be resolved in the given data. References relative to a dot that can not be
+// determined statically (eg. within range) or guarded by if are left to the execution with missingkey=error.
+func undefinedReferences(tmpl *template.Template, data map[string]interface{}) []string {
+	if tmpl.Tree == nil {
+		return nil
+	}
+	w := &referenceWalker{tree: tmpl.Tree, root: data}
+	w.walk(tmpl.Tree.Root, data, true)
+	return w.undefined
+}
+
+type referenceWalker struct {
+	tree      *parse.Tree
+	root      map[string]interface{}
+	undefined []string
+}
+
+// walk visits node with the given dot, known is false if dot can not be determined statically
+func (w *referenceWalker) walk(node parse.Node, dot interface{}, known bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, dot, known)
+		}
+	case *parse.ActionNode:
+		w.walk(n.Pipe, dot, known)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			w.walk(cmd, dot, known)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			w.walk(arg, dot, known)
+		}
+	case *parse.FieldNode:
+		if known {
+			w.check(n, ".", dot, n.Ident)
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			w.check(n, "$.", w.root, n.Ident[1:])
+		}
+	case *parse.IfNode:
+		// The branches are guarded by the condition (eg. {{ if has .settings "x" }}),
+		// references in them are left to the execution with missingkey=error
+		w.walk(n.Pipe, dot, known)
+	case *parse.WithNode:
+		w.walk(n.Pipe, dot, known)
+		inner, innerKnown := w.resolvePipe(n.Pipe, dot, known)
+		w.walk(n.List, inner, innerKnown)
+		w.walk(n.ElseList, dot, known)
+	case *parse.RangeNode:
+		w.walk(n.Pipe, dot, known)
+		w.walk(n.List, nil, false)
+		w.walk(n.ElseList, dot, known)
+	case *parse.TemplateNode:
+		w.walk(n.Pipe, dot, known)
+	}
+}
+
+// check records the reference if the path of identifiers can not be resolved from value
+func (w *referenceWalker) check(node parse.Node, prefix string, value interface{}, idents []string) {
+	for _, ident := range idents {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			// Not a map (eg. methods on values), can not be verified statically
+			return
+		}
+		value, ok = m[ident]
+		if !ok {
+			w.undefined = append(w.undefined, fmt.Sprintf("%s%s (line %s)", prefix, strings.Join(idents, "."), w.line(node)))
+			return
+		}
+	}
+}
+
+// line returns the line of the node within the template
+func (w *referenceWalker) line(node parse.Node) string {
+	// location has the format name:line:col
+	location, _ := w.tree.ErrorContext(node)
+	parts := strings.Split(location, ":")
+	if len(parts) < 3 {
+		return "?"
+	}
+	return parts[len(parts)-2]
+}
+
+// resolvePipe returns the value of a pipeline consisting of a single field reference
+func (w *referenceWalker) resolvePipe(pipe *parse.PipeNode, dot interface{}, known bool) (interface{}, bool) {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 || len(pipe.Decl) > 0 {
+		return nil, false
+	}
+
+	var value interface{}
+	var idents []string
+	switch n := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		if !known {
+			return nil, false
+		}
+		value, idents = dot, n.Ident
+	case *parse.VariableNode:
+		if len(n.Ident) < 2 || n.Ident[0] != "$" {
+			return nil, false
+		}
+		value, idents = w.root, n.Ident[1:]
+	default:
+		return nil, false
+	}
+
+	for _, ident := range idents {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[ident]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// optionalFunc looks up optional references, which must not fail with missingkey=error
+const optionalFunc = "substOptional"
+
+// optional returns the value at the path of keys below value, or nil if it is not defined
+func optional(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// markOptional rewrites references passed to default (eg. {{ .settings.x | default "d" }}),
+// so they resolve to nil instead of failing, if they are not defined
+func markOptional(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			markOptional(child)
+		}
+	case *parse.ActionNode:
+		markOptional(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for i, cmd := range n.Cmds {
+			if !isDefault(cmd) {
+				markOptional(cmd)
+				continue
+			}
+			// default "d" .settings.x
+			for j := 2; j < len(cmd.Args); j++ {
+				cmd.Args[j] = optionalReference(cmd.Args[j])
+			}
+			// .settings.x | default "d"
+			if i > 0 && len(n.Cmds[i-1].Args) == 1 {
+				n.Cmds[i-1].Args[0] = optionalReference(n.Cmds[i-1].Args[0])
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			markOptional(arg)
+		}
+	case *parse.IfNode:
+		markOptional(n.Pipe)
+		markOptional(n.List)
+		markOptional(n.ElseList)
+	case *parse.WithNode:
+		markOptional(n.Pipe)
+		markOptional(n.List)
+		markOptional(n.ElseList)
+	case *parse.RangeNode:
+		markOptional(n.Pipe)
+		markOptional(n.List)
+		markOptional(n.ElseList)
+	case *parse.TemplateNode:
+		markOptional(n.Pipe)
+	}
+}
+
+// isDefault reports whether the command calls the default function
+func isDefault(cmd *parse.CommandNode) bool {
+	if len(cmd.Args) == 0 {
+		return false
+	}
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	return ok && ident.Ident == "default"
+}
+
+// optionalReference replaces a field (.a.b) or variable reference ($.a.b) by a call of optionalFunc
+func optionalReference(node parse.Node) parse.Node {
+	var receiver parse.Node
+	var keys []string
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		receiver, keys = &parse.DotNode{NodeType: parse.NodeDot, Pos: n.Pos}, n.Ident
+	case *parse.VariableNode:
+		if len(n.Ident) < 2 {
+			return node
+		}
+		receiver, keys = &parse.VariableNode{NodeType: parse.NodeVariable, Pos: n.Pos, Ident: n.Ident[:1]}, n.Ident[1:]
+	default:
+		return node
+	}
+
+	args := []parse.Node{parse.NewIdentifier(optionalFunc).SetPos(node.Position()), receiver}
+	for _, key := range keys {
+		args = append(args, &parse.StringNode{NodeType: parse.NodeString, Pos: node.Position(), Quoted: strconv.Quote(key), Text: key})
+	}
+	return &parse.PipeNode{
+		NodeType: parse.NodePipe,
+		Pos:      node.Position(),
+		Cmds:     []*parse.CommandNode{{NodeType: parse.NodeCommand, Pos: node.Position(), Args: args}},
+	}
+}
@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 	flag "github.com/spf13/pflag"
@@ -14,13 +16,44 @@ import (
 
 type Configuration struct {
 	EnvRegex              string   `mapstructure:"env-regex"`
+	EnvSeparator          string   `mapstructure:"env-separator"`
+	TemplateEnv           []string `mapstructure:"template-env"`
+	Sandbox               string   `mapstructure:"sandbox"`
 	RootDirectory         string   `mapstructure:"root-dir"`
 	EjsonKey              []string `mapstructure:"ejson-key"`
 	SkipDecrypt           bool     `mapstructure:"skip-decrypt"`
+	EjsonMerge            bool     `mapstructure:"ejson-merge"`
+	SopsAgeKeyFile        []string `mapstructure:"sops-age-key-file"`
 	Output                string   `mapstructure:"output"`
+	JSONMode              string   `mapstructure:"json-mode"`
+	OutputDir             string   `mapstructure:"output-dir"`
+	OutputNaming          string   `mapstructure:"output-naming"`
 	KustomizeBuildOptions string   `mapstructure:"kustomize-build-options"`
+	Strict                bool     `mapstructure:"strict"`
+	PreBuild              bool     `mapstructure:"pre-build"`
+	LeftDelim             string   `mapstructure:"left-delim"`
+	RightDelim            string   `mapstructure:"right-delim"`
+	Passthrough           []string `mapstructure:"passthrough"`
+	Spruce                bool     `mapstructure:"spruce"`
+	Validate              bool     `mapstructure:"validate"`
+	SchemaDir             []string `mapstructure:"schema-dir"`
+	Values                []string `mapstructure:"values"`
+	Set                   []string `mapstructure:"set"`
+	SetString             []string `mapstructure:"set-string"`
+	SetFile               []string `mapstructure:"set-file"`
 }
 
+const (
+	// ConfigFileName is the name of the project configuration file, discovered upwards from the root directory
+	ConfigFileName = ".subst.yaml"
+	// EnvPrefix is the prefix for environment variables overriding configuration (eg. SUBST_ENV_REGEX)
+	EnvPrefix = "SUBST"
+)
+
+// LoadConfiguration loads the configuration with the following precedence (highest first):
+// flags, SUBST_* environment variables, the configuration file and flag defaults.
+// The configuration file is either given by cfgFile or discovered as .subst.yaml upwards from the directory.
+// If directory is empty, the root directory from the configuration is used (default: current directory).
 func LoadConfiguration(cfgFile string, cmd *cobra.Command, directory string) (*Configuration, error) {
 	v := viper.New()
 
@@ -33,13 +66,75 @@ func LoadConfiguration(cfgFile string, cmd *cobra.Command, directory string) (*C
 		}
 	})
 
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+	// Bind all configuration keys, so they are also read from the environment when there is no flag
+	for _, key := range configurationKeys() {
+		if err := v.BindEnv(key); err != nil {
+			return nil, fmt.Errorf("failed binding environment for %q: %w", key, err)
+		}
+	}
+
+	searchDir := directory
+	if searchDir == "" {
+		searchDir = "."
+	}
+	if cfgFile == "" {
+		cfgFile = findConfigFile(searchDir)
+	}
+	if cfgFile != "" {
+		v.SetConfigFile(cfgFile)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed reading configuration file %s: %w", cfgFile, err)
+		}
+		log.Debug().Msgf("Using configuration file: %s", cfgFile)
+	}
+
 	cfg := &Configuration{}
 	if err := v.Unmarshal(cfg); err != nil {
 		return nil, fmt.Errorf("failed unmarshaling configuration: %w", err)
 	}
 
 	// Root Directory
-	cfg.RootDirectory = directory
+	if directory != "" {
+		cfg.RootDirectory = directory
+	} else if cfg.RootDirectory != "" && cfgFile != "" && !filepath.IsAbs(cfg.RootDirectory) && v.InConfig("root-dir") {
+		// Relative root directories in the configuration file are relative to the file
+		cfg.RootDirectory = filepath.Join(filepath.Dir(cfgFile), cfg.RootDirectory)
+	}
+	if cfg.RootDirectory == "" {
+		cfg.RootDirectory = "."
+	}
+	rootAbs, err := filepath.Abs(cfg.RootDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed resolving root directory: %w", err)
+	}
+	cfg.RootDirectory = rootAbs
+
+	// Relative output directories in the configuration file are relative to the file
+	if cfg.OutputDir != "" && cfgFile != "" && !filepath.IsAbs(cfg.OutputDir) && v.InConfig("output-dir") && !cmd.Flags().Changed("output-dir") {
+		cfg.OutputDir = filepath.Join(filepath.Dir(cfgFile), cfg.OutputDir)
+	}
+
+	// Relative schema directories in the configuration file are relative to the file
+	if cfgFile != "" && v.InConfig("schema-dir") && !cmd.Flags().Changed("schema-dir") {
+		for i, dir := range cfg.SchemaDir {
+			if !filepath.IsAbs(dir) {
+				cfg.SchemaDir[i] = filepath.Join(filepath.Dir(cfgFile), dir)
+			}
+		}
+	}
+
+	// Relative values files in the configuration file are relative to the file
+	if cfgFile != "" && v.InConfig("values") && !cmd.Flags().Changed("values") {
+		for i, file := range cfg.Values {
+			if !filepath.IsAbs(file) {
+				cfg.Values[i] = filepath.Join(filepath.Dir(cfgFile), file)
+			}
+		}
+	}
 
 	// Set kustomize build options from environment if not set via flag
 	if cfg.KustomizeBuildOptions == "" {
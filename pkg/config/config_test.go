@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("env-regex", "^ARGOCD_ENV_.*$", "")
+	cmd.Flags().Bool("strict", false, "")
+	return cmd
+}
+
+func TestLoadConfigurationDiscoversFile(t *testing.T) {
+	root := t.TempDir()
+	overlay := filepath.Join(root, "clusters", "cluster-01")
+	assert.NoError(t, os.MkdirAll(overlay, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, ConfigFileName), []byte("strict: true\nenv-regex: ^APP_.*$\n"), 0o600))
+
+	cfg, err := LoadConfiguration("", testCommand(), overlay)
+	assert.NoError(t, err)
+	assert.True(t, cfg.Strict)
+	assert.Equal(t, "^APP_.*$", cfg.EnvRegex)
+	assert.Equal(t, overlay, cfg.RootDirectory)
+}
+
+func TestLoadConfigurationPrecedence(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "config.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("strict: true\nenv-regex: ^FILE_.*$\nskip-decrypt: true\nroot-dir: overlay\n"), 0o600))
+	t.Setenv("SUBST_ENV_REGEX", "^ENV_.*$")
+
+	cmd := testCommand()
+	assert.NoError(t, cmd.Flags().Set("strict", "false"))
+
+	cfg, err := LoadConfiguration(file, cmd, "")
+	assert.NoError(t, err)
+	assert.False(t, cfg.Strict, "flags take precedence")
+	assert.Equal(t, "^ENV_.*$", cfg.EnvRegex, "environment takes precedence over the file")
+	assert.True(t, cfg.SkipDecrypt, "fields without flags are read from the file")
+	assert.Equal(t, filepath.Join(root, "overlay"), cfg.RootDirectory, "root directory is relative to the file")
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 )
 
@@ -15,3 +18,35 @@ func getValueAfterUnderscore(input string) string {
 
 	return matches[1]
 }
+
+// configurationKeys returns the keys of all configuration fields
+func configurationKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(Configuration{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("mapstructure"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// findConfigFile searches the configuration file from the directory upwards,
+// returns an empty string if there is none
+func findConfigFile(directory string) string {
+	current, err := filepath.Abs(directory)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(current, ConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+		current = parent
+	}
+}
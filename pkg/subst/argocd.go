@@ -0,0 +1,57 @@
+package subst
+
+import "os"
+
+// ArgoCDNamespace is the key of the ArgoCD application context in the substitutions
+const ArgoCDNamespace = "argocd"
+
+// argocdVariable maps an ArgoCD build environment variable to a path in the application context
+type argocdVariable struct {
+	name string
+	path []string
+}
+
+// argocdVariables are the build environment variables ArgoCD passes to config management plugins
+var argocdVariables = []argocdVariable{
+	{"ARGOCD_APP_NAME", []string{"name"}},
+	{"ARGOCD_APP_NAMESPACE", []string{"namespace"}},
+	{"ARGOCD_APP_REVISION", []string{"revision"}},
+	{"ARGOCD_APP_REVISION_SHORT", []string{"revisionShort"}},
+	{"ARGOCD_APP_SOURCE_PATH", []string{"source", "path"}},
+	{"ARGOCD_APP_SOURCE_REPO_URL", []string{"source", "repoURL"}},
+	{"ARGOCD_APP_SOURCE_TARGET_REVISION", []string{"source", "targetRevision"}},
+}
+
+// shortRevisionLength is the length of the short revision, if ArgoCD does not provide it
+const shortRevisionLength = 7
+
+// loadArgoCDContext adds the ArgoCD application context from the build environment under .argocd.
+// Nothing is added outside of ArgoCD.
+func (s *Subst) loadArgoCDContext() {
+	for _, v := range argocdVariables {
+		value := os.Getenv(v.name)
+		if value == "" {
+			continue
+		}
+		s.merge(argocdValue(value, v.path...), Source{Name: "env:" + v.name})
+	}
+
+	// Older ArgoCD versions do not provide the short revision
+	revision := os.Getenv("ARGOCD_APP_REVISION")
+	if os.Getenv("ARGOCD_APP_REVISION_SHORT") == "" && revision != "" {
+		short := revision
+		if len(short) > shortRevisionLength {
+			short = short[:shortRevisionLength]
+		}
+		s.merge(argocdValue(short, "revisionShort"), Source{Name: "env:ARGOCD_APP_REVISION"})
+	}
+}
+
+// argocdValue returns value nested at path in the application context
+func argocdValue(value string, path ...string) map[string]interface{} {
+	var nested interface{} = value
+	for i := len(path) - 1; i >= 0; i-- {
+		nested = map[string]interface{}{path[i]: nested}
+	}
+	return map[string]interface{}{ArgoCDNamespace: nested}
+}
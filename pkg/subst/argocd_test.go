@@ -0,0 +1,35 @@
+package subst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadArgoCDContext(t *testing.T) {
+	t.Setenv("ARGOCD_APP_NAME", "app")
+	t.Setenv("ARGOCD_APP_NAMESPACE", "argocd")
+	t.Setenv("ARGOCD_APP_REVISION", "0123456789abcdef")
+	t.Setenv("ARGOCD_APP_REVISION_SHORT", "")
+	t.Setenv("ARGOCD_APP_SOURCE_PATH", "clusters/cluster-01")
+	t.Setenv("ARGOCD_APP_SOURCE_REPO_URL", "https://github.com/example/repo")
+	t.Setenv("ARGOCD_APP_SOURCE_TARGET_REVISION", "main")
+
+	s := &Subst{Substitutions: map[string]interface{}{}, Provenance: Provenance{}}
+	s.loadArgoCDContext()
+
+	assert.Equal(t, map[string]interface{}{
+		"argocd": map[string]interface{}{
+			"name":          "app",
+			"namespace":     "argocd",
+			"revision":      "0123456789abcdef",
+			"revisionShort": "0123456",
+			"source": map[string]interface{}{
+				"path":           "clusters/cluster-01",
+				"repoURL":        "https://github.com/example/repo",
+				"targetRevision": "main",
+			},
+		},
+	}, s.Substitutions)
+	assert.Equal(t, []Source{{Name: "env:ARGOCD_APP_REVISION"}}, s.Provenance.Sources("argocd.revisionShort"))
+}
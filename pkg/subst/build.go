@@ -1,15 +1,20 @@
 package subst
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
+	"slices"
+	"unicode/utf8"
 
 	"github.com/kubelize/subst/internal/decryptors"
 	"github.com/kubelize/subst/internal/decryptors/ejson"
+	"github.com/kubelize/subst/internal/decryptors/sops"
 	"github.com/kubelize/subst/internal/kustomize"
+	"github.com/kubelize/subst/internal/spruce"
 	"github.com/kubelize/subst/internal/utils"
 	"github.com/kubelize/subst/internal/wrapper"
 	"github.com/kubelize/subst/pkg/config"
@@ -21,20 +26,39 @@ import (
 type Subst struct {
 	Kustomization  *kustomize.Kustomize
 	Manifests      [][]byte // Store as byte slices for simplicity
+	Sources        []string // Origin file of each manifest (parallel to Manifests)
 	Substitutions  map[string]interface{}
+	Provenance     Provenance            // Sources of all substitution values
 	EjsonDecryptor *ejson.EjsonDecryptor // Add ejson decryptor
+	SopsDecryptor  *sops.SopsDecryptor   // SOPS decryptor using age keys
 	Config         config.Configuration  // Store full config for ejson keys
+
+	templateEnv map[string]string // Environment visible to the env functions of templates
+	built       bool              // Whether the kustomize build ran
+	rendered    map[string]bool   // Absolute paths of the sources rendered by the pre-build templating
+	secrets     []string          // Decrypted leaf values of all secret files, to redact them in output
+	sandboxed   *wrapper.Sandbox  // Restrictions of the template functions, nil if not sandboxed
 }
 
 // NewSubst creates a new simplified Subst instance
 func NewSubst(config config.Configuration) (*Subst, error) {
-	k, err := kustomize.NewKustomize(config.RootDirectory, config.KustomizeBuildOptions)
+	buildOptions, err := kustomize.ParseBuildOptions(config.KustomizeBuildOptions)
 	if err != nil {
 		return nil, err
 	}
 
+	// The kustomization graph is resolved first, the build runs with Build after all substitutions are loaded
+	k := kustomize.NewKustomize(config.RootDirectory, buildOptions)
+
 	// Get environment variables that match the regex
-	envVars, err := GetVariables(config.EnvRegex)
+	envVars, envNames, err := getVariables(config.EnvRegex)
+	if err != nil {
+		return nil, err
+	}
+	nestedEnvVars := nestedVariables(envVars, envNames, config.EnvSeparator)
+
+	// Templates only see the environment matching the regex and explicitly allowed variables
+	templateEnv, err := templateEnvironment(config.EnvRegex, config.TemplateEnv)
 	if err != nil {
 		return nil, err
 	}
@@ -61,12 +85,38 @@ func NewSubst(config config.Configuration) (*Subst, error) {
 		return nil, fmt.Errorf("failed to initialize ejson decryptor: %w", err)
 	}
 
+	sopsDecryptor, err := sops.NewSOPSDecryptor(
+		decryptors.DecryptorConfig{SkipDecrypt: config.SkipDecrypt},
+		sopsAgeKeyFiles(config.SopsAgeKeyFile)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize sops decryptor: %w", err)
+	}
+	if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+		if err := sopsDecryptor.AddKey(key); err != nil {
+			return nil, fmt.Errorf("invalid age key in SOPS_AGE_KEY: %w", err)
+		}
+	}
+
 	subst := &Subst{
 		Kustomization:  k,
 		Manifests:      [][]byte{},
-		Substitutions:  envVars,
+		Substitutions:  map[string]interface{}{},
+		Provenance:     Provenance{},
 		EjsonDecryptor: ejsonDecryptor,
+		SopsDecryptor:  sopsDecryptor,
 		Config:         config,
+		templateEnv:    templateEnv,
+	}
+	if subst.sandboxed, err = subst.sandbox(); err != nil {
+		return nil, err
+	}
+	if subst.sandboxed != nil {
+		log.Debug().Msgf("Sandboxing templates to %s", subst.sandboxed.Root)
+	}
+
+	for key, value := range envVars {
+		subst.merge(map[string]interface{}{key: value}, Source{Name: "env:" + envNames[key]})
 	}
 
 	// Load subst.yaml files from kustomize paths
@@ -81,53 +131,80 @@ func NewSubst(config config.Configuration) (*Subst, error) {
 		log.Warn().Msgf("Failed to load ejson files: %v", err)
 	}
 
+	err = subst.loadSopsFiles()
+	if err != nil {
+		log.Warn().Msgf("Failed to load sops files: %v", err)
+	}
+
+	// Nested environment variables override files, flat variables have the lowest precedence
+	for _, name := range slices.Sorted(maps.Keys(nestedEnvVars)) {
+		subst.merge(nestedEnvVars[name], Source{Name: "env:" + name})
+	}
+
+	// The application context of ArgoCD, independent of the environment regex
+	subst.loadArgoCDContext()
+	if err := subst.loadParameters(); err != nil {
+		return nil, err
+	}
+
+	// Overrides are merged last, so they win over all files and environment variables
+	if err := subst.loadOverrides(); err != nil {
+		return nil, err
+	}
+
+	// Substitutions may reference each other with spruce operators
+	if config.Spruce {
+		if subst.Substitutions, err = spruce.EvaluateData(subst.Substitutions); err != nil {
+			return nil, fmt.Errorf("failed to evaluate spruce operators in substitutions: %w", err)
+		}
+	}
+
 	return subst, nil
 }
 
-// loadSubstFiles loads subst.yaml files following kustomization resource structure
-// Loads from: ancestors (parents), root, and resource directories from kustomization.yaml
-// This ensures only active overlays are loaded (respecting commented resources)
-func (s *Subst) loadSubstFiles() error {
-	// Collect all relevant paths in order
-	pathsToLoad := make(map[string]bool)
-	
-	// 1. Add ancestor paths (going up from root)
-	currentPath := s.Kustomization.Root
-	for {
-		pathsToLoad[currentPath] = true
-		parentPath := filepath.Dir(currentPath)
-		
-		// Stop at filesystem root or when we can't go higher
-		if parentPath == currentPath || parentPath == "/" || parentPath == "." {
-			break
-		}
-		currentPath = parentPath
-	}
-	
-	// 2. Add resource paths from kustomization.yaml (only active, not commented)
-	for _, resourcePath := range s.Kustomization.GetResourcePaths() {
-		absPath, err := filepath.Abs(resourcePath)
-		if err == nil {
-			pathsToLoad[absPath] = true
+// templateSource renders a source file of the kustomization graph with the substitutions before kustomize reads it.
+// Files without template delimiters (or spruce operators) or which are not text are not modified.
+func (s *Subst) templateSource(path string, content []byte) ([]byte, error) {
+	s.markRendered(path)
+	leftDelim := s.Config.LeftDelim
+	if leftDelim == "" {
+		leftDelim = "{{"
+	}
+	template := bytes.Contains(content, []byte(leftDelim))
+	operators := s.Config.Spruce && spruce.Contains(content) && isYAMLFile(path)
+	if (!template && !operators) || !utf8.Valid(content) {
+		return content, nil
+	}
+	if s.passthroughSource(path, content) {
+		log.Debug().Msgf("Passing through kustomize source %s", path)
+		return content, nil
+	}
+	log.Debug().Msgf("Rendering kustomize source %s", path)
+	if template {
+		rendered, err := wrapper.ProcessGomplateTemplate(content, s.Substitutions, s.templateOptions())
+		if err != nil {
+			return nil, err
 		}
+		content = rendered
 	}
-	
-	// Convert to sorted list (shallowest first for proper override order)
-	var paths []string
-	for path := range pathsToLoad {
-		paths = append(paths, path)
-	}
-	
-	// Sort by depth - shallower paths first (fewer separators)
-	// This ensures parent configs are loaded before child configs
-	sort.Slice(paths, func(i, j int) bool {
-		return strings.Count(paths[i], string(filepath.Separator)) < 
-		       strings.Count(paths[j], string(filepath.Separator))
-	})
-	
-	// Load subst.yaml files in order (parents first, then children)
-	// Deep merge ensures child values override parent values
-	for _, path := range paths {
+	return s.evaluateSpruce(content)
+}
+
+// templateOptions returns the options for templating from the configuration
+func (s *Subst) templateOptions() wrapper.Options {
+	return wrapper.Options{
+		Strict:     s.Config.Strict,
+		LeftDelim:  s.Config.LeftDelim,
+		RightDelim: s.Config.RightDelim,
+		Env:        s.templateEnv,
+		Sandbox:    s.sandboxed,
+	}
+}
+
+// loadSubstFiles loads subst.yaml files following the kustomization graph.
+// Files are loaded lowest precedence first, deep merging lets later files override earlier ones.
+func (s *Subst) loadSubstFiles() error {
+	for _, path := range s.paths() {
 		err := s.loadSubstFromPath(path)
 		if err != nil {
 			log.Debug().Msgf("No subst file in %s: %v", path, err)
@@ -137,6 +214,28 @@ func (s *Subst) loadSubstFiles() error {
 	return nil
 }
 
+// paths returns the directories to load substitutions from, lowest precedence first:
+// ancestors of the root directory (outermost first), followed by the kustomization graph
+// (patches, resources, components and finally the root directory itself)
+func (s *Subst) paths() []string {
+	graph := s.Kustomization.GetPaths()
+
+	var ancestors []string
+	currentPath := filepath.Dir(s.Kustomization.Root)
+	for currentPath != "/" && currentPath != "." && currentPath != filepath.Dir(currentPath) {
+		if !slices.Contains(graph, currentPath) {
+			ancestors = append([]string{currentPath}, ancestors...)
+		}
+		currentPath = filepath.Dir(currentPath)
+	}
+
+	paths := ancestors
+	for i := len(graph) - 1; i >= 0; i-- {
+		paths = append(paths, graph[i])
+	}
+	return paths
+}
+
 // loadSubstFromPath loads subst.yaml files from a specific path
 func (s *Subst) loadSubstFromPath(basePath string) error {
 	entries, err := os.ReadDir(basePath)
@@ -159,7 +258,7 @@ func (s *Subst) loadSubstFromPath(basePath string) error {
 			}
 
 			// Deep merge subst data into substitutions
-			s.Substitutions = utils.DeepMerge(s.Substitutions, substData)
+			s.merge(substData, Source{Name: filePath})
 		}
 	}
 
@@ -188,89 +287,97 @@ func (s *Subst) loadEjsonFiles() error {
 	if s.EjsonDecryptor == nil {
 		return nil
 	}
+	return s.loadSecretFiles("ejson", s.EjsonDecryptor, s.Config.EjsonMerge, ".ejson")
+}
 
-	// Find all .ejson files in the kustomize directory tree
-	ejsonFiles, err := s.findEjsonFiles()
+// loadSopsFiles finds and decrypts sops files, making their values available for substitution
+func (s *Subst) loadSopsFiles() error {
+	if s.SopsDecryptor == nil {
+		return nil
+	}
+	return s.loadSecretFiles("sops", s.SopsDecryptor, false, ".sops.yaml", ".sops.yml", ".sops.json")
+}
+
+// loadSecretFiles decrypts all files with one of the suffixes and adds their content
+// under the namespace, keyed by file (eg. .ejson.app_secret)
+func (s *Subst) loadSecretFiles(namespace string, decryptor decryptors.Decryptor, merge bool, suffixes ...string) error {
+	files, err := s.findFiles(suffixes...)
 	if err != nil {
-		return fmt.Errorf("failed to find ejson files: %w", err)
+		return fmt.Errorf("failed to find %s files: %w", namespace, err)
 	}
 
-	for _, ejsonFile := range ejsonFiles {
-		log.Debug().Msgf("Processing ejson file for substitution: %s", ejsonFile)
+	for _, file := range files {
+		log.Debug().Msgf("Processing %s file for substitution: %s", namespace, file)
 
-		// Read the ejson file
-		content, err := os.ReadFile(ejsonFile)
+		content, err := os.ReadFile(file)
 		if err != nil {
-			log.Warn().Msgf("Failed to read ejson file %s: %v", ejsonFile, err)
+			log.Warn().Msgf("Failed to read %s file %s: %v", namespace, file, err)
 			continue
 		}
 
 		// Check if it's encrypted
-		isEncrypted, err := s.EjsonDecryptor.IsEncrypted(content)
+		isEncrypted, err := decryptor.IsEncrypted(content)
 		if err != nil || !isEncrypted {
-			log.Debug().Msgf("File %s is not encrypted ejson, skipping", ejsonFile)
+			log.Debug().Msgf("File %s is not encrypted %s, skipping", file, namespace)
 			continue
 		}
 
 		// Decrypt the file
-		decryptedData, err := s.EjsonDecryptor.Decrypt(content)
+		decryptedData, err := decryptor.Decrypt(content)
 		if err != nil {
-			log.Warn().Msgf("Failed to decrypt ejson file %s: %v", ejsonFile, err)
+			log.Warn().Msgf("Failed to decrypt %s file %s: %v", namespace, file, err)
 			continue
 		}
-		log.Debug().Msgf("Successfully decrypted ejson file %s with %d fields", ejsonFile, len(decryptedData))
-
-		// Add the decrypted data under the ejson namespace to avoid conflicts
-		if ejsonData, exists := s.Substitutions["ejson"]; exists {
-			if ejsonMap, ok := ejsonData.(map[string]interface{}); ok {
-				// Merge into existing ejson namespace
-				for key, value := range decryptedData {
-					// Skip the _public_key field
-					if key != "_public_key" {
-						ejsonMap[key] = value
-					}
-				}
-			}
-		} else {
-			// Create new ejson namespace
-			ejsonNamespace := make(map[string]interface{})
-			for key, value := range decryptedData {
-				// Skip the _public_key field
-				if key != "_public_key" {
-					ejsonNamespace[key] = value
-				}
-			}
-			s.Substitutions["ejson"] = ejsonNamespace
+		log.Debug().Msgf("Successfully decrypted %s file %s with %d fields", namespace, file, len(decryptedData))
+
+		// Add the decrypted data under the namespace, keyed by file, to avoid conflicts
+		encryptedData, err := decryptors.UnmarshalJSONorYAML(content)
+		if err != nil {
+			log.Warn().Msgf("Failed to parse %s file %s: %v", namespace, file, err)
+			continue
 		}
-		log.Debug().Msgf("Successfully loaded ejson file %s under .ejson namespace", ejsonFile)
+		s.addSecrets(decryptedData, encryptedData)
+		source := Source{Name: file, Secret: true}
+		key := namespaceKey(file, suffixes...)
+		s.merge(map[string]interface{}{
+			namespace: map[string]interface{}{key: decryptedData},
+		}, source)
+
+		// Optionally merge the content of all files directly into the namespace
+		if merge {
+			s.merge(map[string]interface{}{namespace: decryptedData}, source)
+		}
+		log.Debug().Msgf("Successfully loaded %s file %s under .%s.%s namespace", namespace, file, namespace, key)
 	}
 
 	return nil
 }
 
-// findEjsonFiles finds all .ejson files in the current directory and subdirectories
-func (s *Subst) findEjsonFiles() ([]string, error) {
-	var ejsonFiles []string
+// findFiles finds files with one of the suffixes in the same directories as subst.yaml files, lowest precedence first.
+// Directories are not searched recursively, so only files of the active kustomization paths are found.
+func (s *Subst) findFiles(suffixes ...string) ([]string, error) {
+	var files []string
 
-	err := filepath.Walk(s.Config.RootDirectory, func(path string, info os.FileInfo, err error) error {
+	for _, path := range s.paths() {
+		entries, err := os.ReadDir(path)
 		if err != nil {
-			return err
+			log.Debug().Msgf("Failed to read directory %s: %v", path, err)
+			continue
 		}
 
-		if !info.IsDir() && strings.HasSuffix(path, ".ejson") {
-			ejsonFiles = append(ejsonFiles, path)
+		for _, entry := range entries {
+			if entry.IsDir() || !hasSuffix(entry.Name(), suffixes...) {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
 		}
+	}
 
-		return nil
-	})
-
-	return ejsonFiles, err
+	return files, nil
 }
 
-
-
-
-// Build processes kustomize output with gomplate templates
+// Build processes kustomize output with gomplate templates.
+// Each resource is templated separately, failures are collected and reported per resource.
 func (s *Subst) Build() error {
 	if s.Kustomization == nil {
 		return fmt.Errorf("no kustomization configured")
@@ -278,23 +385,118 @@ func (s *Subst) Build() error {
 
 	log.Debug().Msg("Building resources with simplified approach")
 
-	// Get YAML output from kustomize
-	yamlContent := s.Kustomization.GetYAML()
-	if yamlContent == "" {
+	if err := s.build(); err != nil {
+		return err
+	}
+	resources := s.Kustomization.GetResources()
+	if len(resources) == 0 {
 		return fmt.Errorf("kustomize produced no output")
 	}
 
 	// Use all substitution data directly for gomplate processing
 	log.Debug().Msgf("Template data: %+v", s.Substitutions)
 
-	// Process the entire YAML content with gomplate
-	processedYAMLBytes, err := wrapper.ProcessGomplateTemplate([]byte(yamlContent), s.Substitutions, "")
-	if err != nil {
-		return fmt.Errorf("failed to process with gomplate: %w", err)
+	manifests := make([][]byte, 0, len(resources))
+	sources := make([]string, 0, len(resources))
+	var errs []error
+	for _, resource := range resources {
+		processed, passthrough, err := s.passthrough(resource.Origin, resource.YAML)
+		if err != nil {
+			errs = append(errs, resourceError(resource, err))
+			continue
+		}
+		if passthrough {
+			log.Debug().Msgf("Passing through %s", resource)
+		} else if s.preRendered(resource) {
+			// Rendering again would evaluate substituted values and escaped delimiters
+			log.Debug().Msgf("Skipping templating of %s, its sources were rendered before the build", resource)
+			passthrough = true
+		} else {
+			processed, err = wrapper.ProcessGomplateTemplate(resource.YAML, s.Substitutions, s.templateOptions())
+		}
+		if err != nil {
+			errs = append(errs, resourceError(resource, err))
+			continue
+		}
+
+		// Templates may break the YAML structure, parse the output and drop empty documents
+		normalized, err := utils.NormalizeYAML(processed)
+		if err != nil {
+			errs = append(errs, resourceError(resource, err))
+			continue
+		}
+		if len(normalized) == 0 {
+			log.Debug().Msgf("Dropping %s, templating produced no output", resource)
+			continue
+		}
+		if !passthrough {
+			if normalized, err = s.evaluateSpruce(normalized); err != nil {
+				errs = append(errs, resourceError(resource, err))
+				continue
+			}
+		}
+		manifests = append(manifests, normalized)
+		sources = append(sources, resource.Origin)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to process %d of %d resource(s) with gomplate:\n%w", len(errs), len(resources), errors.Join(errs...))
 	}
 
-	s.Manifests = [][]byte{processedYAMLBytes}
+	s.Manifests = manifests
+	s.Sources = sources
 
 	log.Debug().Msgf("Built %d manifest(s)", len(s.Manifests))
 	return nil
 }
+
+// build runs the kustomize build once. With pre-build templating the sources are rendered with the substitutions before.
+func (s *Subst) build() error {
+	if s.built {
+		return nil
+	}
+	var transform kustomize.SourceTransformer
+	if s.Config.PreBuild {
+		transform = s.templateSource
+	}
+	if err := s.Kustomization.Build(transform); err != nil {
+		return err
+	}
+	s.built = true
+	return nil
+}
+
+// markRendered records that the source at path was rendered before the build
+func (s *Subst) markRendered(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		if s.rendered == nil {
+			s.rendered = map[string]bool{}
+		}
+		s.rendered[abs] = true
+	}
+}
+
+// preRendered reports whether the resource was built from a source rendered before the build.
+// Resources without origin or from remote bases are only templated after the build.
+func (s *Subst) preRendered(resource kustomize.Resource) bool {
+	if resource.Origin == "" || len(s.rendered) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(filepath.Join(s.Kustomization.Root, resource.Origin))
+	return err == nil && s.rendered[abs]
+}
+
+// resourceError attributes an error to a kustomize resource and its origin file
+func resourceError(resource kustomize.Resource, err error) error {
+	if resource.Origin == "" {
+		return fmt.Errorf("%s: %w", resource, err)
+	}
+	return fmt.Errorf("%s (%s): %w", resource, resource.Origin, err)
+}
+
+// evaluateSpruce evaluates spruce operators in content, if enabled
+func (s *Subst) evaluateSpruce(content []byte) ([]byte, error) {
+	if !s.Config.Spruce || !spruce.Contains(content) {
+		return content, nil
+	}
+	return spruce.Evaluate(content, s.Substitutions)
+}
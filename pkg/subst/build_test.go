@@ -0,0 +1,170 @@
+package subst
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kubelize/subst/internal/kustomize"
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// plainDecryptor treats every file as encrypted and returns its JSON content
+type plainDecryptor struct{}
+
+func (plainDecryptor) IsEncrypted(data []byte) (bool, error) {
+	return true, nil
+}
+
+func (plainDecryptor) Decrypt(data []byte) (map[string]interface{}, error) {
+	var content map[string]interface{}
+	err := json.Unmarshal(data, &content)
+	return content, err
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSecretFilesMerged(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "kustomization.yaml"), "resources: []\n")
+	writeFile(t, filepath.Join(tmp, "app-secret.ejson"), `{"data": {"a": 1}}`)
+	writeFile(t, filepath.Join(tmp, "registry-secret.ejson"), `{"data": {"b": 2}}`)
+
+	s := &Subst{
+		Kustomization: kustomize.NewKustomize(tmp, kustomize.DefaultBuildOptions()),
+		Substitutions: map[string]interface{}{},
+		Provenance:    Provenance{},
+		Config:        config.Configuration{EjsonMerge: true},
+	}
+	assert.NoError(t, s.loadSecretFiles("ejson", plainDecryptor{}, true, ".ejson"))
+
+	ejson := s.Substitutions["ejson"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"a": float64(1)}}, ejson["app_secret"])
+	assert.Equal(t, map[string]interface{}{"data": map[string]interface{}{"b": float64(2)}}, ejson["registry_secret"])
+	assert.Equal(t, map[string]interface{}{"a": float64(1), "b": float64(2)}, ejson["data"])
+}
+
+func TestBuildAttributesErrorsToResources(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "kustomization.yaml"), "resources:\n  - app.yaml\n  - broken.yaml\n")
+	writeFile(t, filepath.Join(tmp, "app.yaml"), `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  name: '{{ .name }}'
+`)
+	writeFile(t, filepath.Join(tmp, "broken.yaml"), `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: broken
+  namespace: production
+data:
+  name: '{{ .name '
+`)
+
+	k := kustomize.NewKustomize(tmp, kustomize.DefaultBuildOptions())
+	s := &Subst{Kustomization: k, Substitutions: map[string]interface{}{"name": "app"}}
+
+	err := s.Build()
+	assert.ErrorContains(t, err, "failed to process 1 of 2 resource(s)")
+	assert.ErrorContains(t, err, "ConfigMap production/broken (broken.yaml)")
+	assert.NotContains(t, err.Error(), "ConfigMap app")
+
+	// The other resources still render, the error only excludes the broken resource
+	s.Kustomization.Resources = slices.DeleteFunc(k.Resources, func(r kustomize.Resource) bool {
+		return r.Name == "broken"
+	})
+	assert.NoError(t, s.Build())
+	assert.Len(t, s.Manifests, 1)
+	assert.Contains(t, string(s.Manifests[0]), "name: app")
+	assert.Equal(t, []string{"app.yaml"}, s.Sources)
+}
+
+func TestFindFilesScopedToKustomizationPaths(t *testing.T) {
+	tmp := t.TempDir()
+	prod := filepath.Join(tmp, "overlays", "production")
+	writeFile(t, filepath.Join(tmp, "subst.yaml"), "repository: true\n")
+	writeFile(t, filepath.Join(tmp, "base", "kustomization.yaml"), "resources: []\n")
+	writeFile(t, filepath.Join(tmp, "base", "subst.yaml"), "base: true\n")
+	writeFile(t, filepath.Join(tmp, "base", "base.ejson"), "{}")
+	writeFile(t, filepath.Join(prod, "kustomization.yaml"), "resources:\n  - ../../base\n#  - disabled\n")
+	writeFile(t, filepath.Join(prod, "subst.yaml"), "production: true\n")
+	writeFile(t, filepath.Join(prod, "production.ejson"), "{}")
+	writeFile(t, filepath.Join(prod, "disabled", "kustomization.yaml"), "resources: []\n")
+	writeFile(t, filepath.Join(prod, "disabled", "subst.yaml"), "disabled: true\n")
+	writeFile(t, filepath.Join(prod, "disabled", "disabled.ejson"), "{}")
+	writeFile(t, filepath.Join(tmp, "overlays", "staging", "kustomization.yaml"), "resources:\n  - ../../base\n")
+	writeFile(t, filepath.Join(tmp, "overlays", "staging", "subst.yaml"), "staging: true\n")
+	writeFile(t, filepath.Join(tmp, "overlays", "staging", "staging.ejson"), "{}")
+
+	s := &Subst{
+		Kustomization: kustomize.NewKustomize(prod, kustomize.DefaultBuildOptions()),
+		Substitutions: map[string]interface{}{},
+		Provenance:    Provenance{},
+	}
+
+	files, err := s.findFiles(".ejson")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(tmp, "base", "base.ejson"),
+		filepath.Join(prod, "production.ejson"),
+	}, files)
+
+	assert.NoError(t, s.loadSubstFiles())
+	assert.Equal(t, map[string]interface{}{
+		"repository": true,
+		"base":       true,
+		"production": true,
+	}, s.Substitutions)
+}
+
+func TestBuildRendersPreBuildSourcesOnce(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "kustomization.yaml"), "namespace: '{{ .namespace }}'\nresources:\n  - app.yaml\n")
+	writeFile(t, filepath.Join(tmp, "app.yaml"), `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  password: '{{ .password }}'
+  alert: '{{ "{{" }} $labels.instance {{ "}}" }}'
+`)
+
+	k := kustomize.NewKustomize(tmp, kustomize.DefaultBuildOptions())
+	s := &Subst{
+		Kustomization: k,
+		Substitutions: map[string]interface{}{"namespace": "production", "password": "{{ .namespace }}"},
+		Config:        config.Configuration{RootDirectory: tmp, PreBuild: true},
+	}
+	assert.NoError(t, s.Build())
+
+	assert.Len(t, s.Manifests, 1)
+	assert.Contains(t, string(s.Manifests[0]), "namespace: production")
+	assert.Contains(t, string(s.Manifests[0]), "password: '{{ .namespace }}'")
+	assert.Contains(t, string(s.Manifests[0]), "alert: '{{ $labels.instance }}'")
+}
+
+func TestNewSubstDefersBuild(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "kustomization.yaml"), "resources:\n  - missing.yaml\n")
+	writeFile(t, filepath.Join(tmp, "subst.yaml"), "name: app\n")
+
+	// Loading the substitutions (eg. for vars and parameters) does not require a valid build
+	s, err := NewSubst(config.Configuration{RootDirectory: tmp})
+	assert.NoError(t, err)
+	assert.Equal(t, "app", s.Substitutions["name"])
+
+	assert.ErrorContains(t, s.Build(), "kustomize build failed")
+}
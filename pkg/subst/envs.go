@@ -3,17 +3,27 @@ package subst
 import (
 	"os"
 	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/rs/zerolog/log"
 )
 
 func GetVariables(regex string) (envs map[string]interface{}, err error) {
+	envs, _, err = getVariables(regex)
+	return envs, err
+}
+
+// getVariables returns the matching environment variables and the name of the variable for each key
+func getVariables(regex string) (envs map[string]interface{}, names map[string]string, err error) {
 	envs = make(map[string]interface{})
+	names = make(map[string]string)
 	var r *regexp.Regexp
 
 	if regex != "" {
 		r, err = regexp.Compile(regex)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -35,7 +45,62 @@ func GetVariables(regex string) (envs map[string]interface{}, err error) {
 				key = strings.ReplaceAll(key, "ARGOCD_ENV_", "")
 			}
 			envs[key] = value
+			names[key] = pair[0]
+		}
+	}
+	return envs, names, nil
+}
+
+// nestedVariables moves the variables with separator in their key from envs to nested, typed values.
+// The key is split into a path (eg. settings__app__replicas=3 is settings.app.replicas: 3) and the value
+// is parsed as YAML. Returns the nested values by variable name.
+func nestedVariables(envs map[string]interface{}, names map[string]string, separator string) map[string]map[string]interface{} {
+	nested := make(map[string]map[string]interface{})
+	if separator == "" {
+		return nested
+	}
+	for key, value := range envs {
+		if !strings.Contains(key, separator) {
+			continue
+		}
+		delete(envs, key)
+
+		path := strings.Split(key, separator)
+		if slices.Contains(path, "") {
+			log.Warn().Msgf("Ignoring environment variable %s, it has an empty key segment", names[key])
+			continue
+		}
+
+		typed := yamlValue(value.(string))
+		for i := len(path) - 1; i >= 0; i-- {
+			typed = map[string]interface{}{path[i]: typed}
+		}
+		nested[names[key]] = typed.(map[string]interface{})
+	}
+	return nested
+}
+
+// templateEnvironment returns the environment visible to templates: the variables matching regex
+// (all variables, if regex is empty) and the extra variables given by name.
+// As in getVariables, variables with empty values are not included.
+func templateEnvironment(regex string, extra []string) (map[string]string, error) {
+	var r *regexp.Regexp
+	if regex != "" {
+		var err error
+		if r, err = regexp.Compile(regex); err != nil {
+			return nil, err
+		}
+	}
+
+	env := make(map[string]string)
+	for _, e := range os.Environ() {
+		key, value, _ := strings.Cut(e, "=")
+		if value == "" {
+			continue
+		}
+		if r == nil || r.MatchString(key) || slices.Contains(extra, key) {
+			env[key] = value
 		}
 	}
-	return envs, nil
+	return env, nil
 }
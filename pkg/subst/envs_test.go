@@ -0,0 +1,38 @@
+package subst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNestedVariables(t *testing.T) {
+	t.Setenv("ARGOCD_ENV_settings__app__replicas", "3")
+	t.Setenv("ARGOCD_ENV_settings__app__zones", "[a, b]")
+	t.Setenv("ARGOCD_ENV_settings____empty", "x")
+	t.Setenv("ARGOCD_ENV_NAME", "3")
+
+	envs, names, err := getVariables("^ARGOCD_ENV_")
+	assert.NoError(t, err)
+	nested := nestedVariables(envs, names, "__")
+
+	assert.Equal(t, map[string]interface{}{"NAME": "3"}, envs)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"ARGOCD_ENV_settings__app__replicas": {"settings": map[string]interface{}{"app": map[string]interface{}{"replicas": 3}}},
+		"ARGOCD_ENV_settings__app__zones":    {"settings": map[string]interface{}{"app": map[string]interface{}{"zones": []interface{}{"a", "b"}}}},
+	}, nested)
+}
+
+func TestTemplateEnvironment(t *testing.T) {
+	t.Setenv("ARGOCD_ENV_NAME", "app")
+	t.Setenv("SUBST_TEST_EXTRA", "extra")
+	t.Setenv("SUBST_TEST_HIDDEN", "hidden")
+	t.Setenv("ARGOCD_ENV_EMPTY", "")
+
+	env, err := templateEnvironment("^ARGOCD_ENV_", []string{"SUBST_TEST_EXTRA"})
+	assert.NoError(t, err)
+	assert.Equal(t, "app", env["ARGOCD_ENV_NAME"])
+	assert.Equal(t, "extra", env["SUBST_TEST_EXTRA"])
+	assert.NotContains(t, env, "SUBST_TEST_HIDDEN")
+	assert.NotContains(t, env, "ARGOCD_ENV_EMPTY")
+}
@@ -0,0 +1,279 @@
+package subst
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxIndex limits list indexes of --set assignments, to not allocate huge lists by accident
+const maxIndex = 65536
+
+// assignment is a single key=value of a --set expression
+type assignment struct {
+	key   string
+	path  []interface{} // map keys (string) and list indexes (int)
+	value string
+	list  []string // items of a {a,b,c} value
+}
+
+// loadOverrides merges the values files and the --set, --set-string and --set-file assignments
+// (in this order) on top of all other substitutions
+func (s *Subst) loadOverrides() error {
+	for _, file := range s.Config.Values {
+		log.Debug().Msgf("Loading values file: %s", file)
+		data, err := s.loadSubstFile(file)
+		if err != nil {
+			return err
+		}
+		s.merge(data, Source{Name: file})
+	}
+
+	overrides := []struct {
+		flag        string
+		expressions []string
+		value       func(string) (interface{}, error)
+	}{
+		{"set", s.Config.Set, func(v string) (interface{}, error) { return typedValue(v), nil }},
+		{"set-string", s.Config.SetString, func(v string) (interface{}, error) { return v, nil }},
+		{"set-file", s.Config.SetFile, func(file string) (interface{}, error) {
+			content, err := os.ReadFile(file)
+			return string(content), err
+		}},
+	}
+	for _, o := range overrides {
+		for _, expression := range o.expressions {
+			assignments, err := parseAssignments(expression)
+			if err != nil {
+				return fmt.Errorf("invalid --%s %q: %w", o.flag, expression, err)
+			}
+			for _, a := range assignments {
+				var value interface{}
+				if a.list != nil {
+					items := make([]interface{}, 0, len(a.list))
+					for _, item := range a.list {
+						v, err := o.value(item)
+						if err != nil {
+							return fmt.Errorf("invalid --%s %s: %w", o.flag, a.key, err)
+						}
+						items = append(items, v)
+					}
+					value = items
+				} else if value, err = o.value(a.value); err != nil {
+					return fmt.Errorf("invalid --%s %s: %w", o.flag, a.key, err)
+				}
+
+				data, err := setPath(nil, s.Substitutions, a.path, value)
+				if err != nil {
+					return fmt.Errorf("invalid --%s %s: %w", o.flag, a.key, err)
+				}
+				s.merge(data.(map[string]interface{}), Source{Name: "--" + o.flag + " " + a.key})
+			}
+		}
+	}
+	return nil
+}
+
+// setPath returns current with value set at path. Lists are initialized from base (the current substitutions),
+// so single items of existing lists can be replaced.
+func setPath(current interface{}, base interface{}, path []interface{}, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	switch key := path[0].(type) {
+	case string:
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{}
+		}
+		var child interface{}
+		if b, ok := base.(map[string]interface{}); ok {
+			child = b[key]
+		}
+		v, err := setPath(m[key], child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+		return m, nil
+	case int:
+		list, ok := current.([]interface{})
+		if !ok {
+			if b, ok := base.([]interface{}); ok {
+				list = deepCopy(b).([]interface{})
+			}
+		}
+		for len(list) <= key {
+			list = append(list, nil)
+		}
+		v, err := setPath(list[key], list[key], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[key] = v
+		return list, nil
+	}
+	return nil, fmt.Errorf("invalid path element %v", path[0])
+}
+
+// parseAssignments parses a comma separated list of key=value assignments (eg. a.b=1,c[0]=x,d={x,y}).
+// Backslashes escape the next character (eg. a\.b=1\,2 sets the key "a.b" to "1,2").
+func parseAssignments(expression string) ([]assignment, error) {
+	var assignments []assignment
+	for _, part := range split(expression, ',', true) {
+		kv := split(part, '=', false)
+		if len(kv) < 2 {
+			return nil, fmt.Errorf("%q is not a key=value assignment", part)
+		}
+		key := kv[0]
+		value := strings.TrimPrefix(part, key+"=")
+		path, err := parseKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		a := assignment{key: unescape(key), path: path}
+		if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+			a.list = []string{}
+			if inner := value[1 : len(value)-1]; inner != "" {
+				for _, item := range split(inner, ',', false) {
+					a.list = append(a.list, unescape(item))
+				}
+			}
+		} else {
+			a.value = unescape(value)
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, nil
+}
+
+// parseKey parses a dotted key with list indexes (eg. a.b[0].c)
+func parseKey(key string) ([]interface{}, error) {
+	var path []interface{}
+	for i := 0; i < len(key); {
+		var name strings.Builder
+		for i < len(key) && key[i] != '.' && key[i] != '[' {
+			if key[i] == '\\' && i+1 < len(key) {
+				i++
+			}
+			name.WriteByte(key[i])
+			i++
+		}
+		if name.Len() == 0 {
+			return nil, fmt.Errorf("empty key segment in %q", key)
+		}
+		path = append(path, name.String())
+
+		for i < len(key) && key[i] == '[' {
+			end := strings.IndexByte(key[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in %q", key)
+			}
+			index, err := strconv.Atoi(key[i+1 : i+end])
+			if err != nil || index < 0 || index > maxIndex {
+				return nil, fmt.Errorf("invalid index %q in %q", key[i+1:i+end], key)
+			}
+			path = append(path, index)
+			i += end + 1
+		}
+
+		if i < len(key) {
+			if key[i] != '.' {
+				return nil, fmt.Errorf("unexpected %q in %q", key[i], key)
+			}
+			if i++; i == len(key) {
+				return nil, fmt.Errorf("empty key segment in %q", key)
+			}
+		}
+	}
+	if len(path) == 0 {
+		return nil, errors.New("empty key")
+	}
+	return path, nil
+}
+
+// split splits s at unescaped separators, escapes are kept. If braces is set, separators in {} are ignored.
+func split(s string, separator byte, braces bool) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			if braces {
+				depth++
+			}
+		case '}':
+			if braces && depth > 0 {
+				depth--
+			}
+		case separator:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescape removes the backslashes escaping characters
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// typedValue converts booleans, null and integers like helm --set, all other values are strings
+func typedValue(v string) interface{} {
+	switch strings.ToLower(v) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if v == "0" {
+		return 0
+	}
+	// Values with leading zeros (eg. 0123) stay strings
+	if !strings.HasPrefix(v, "0") {
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return v
+}
+
+// deepCopy copies maps and lists, so overrides do not modify the substitutions they are based on
+func deepCopy(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			m[k] = deepCopy(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, item := range t {
+			l[i] = deepCopy(item)
+		}
+		return l
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package subst
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAssignments(t *testing.T) {
+	assignments, err := parseAssignments(`a.b=1,c[1].d=x,e={x,y\,z},f\.g=h\,i`)
+	assert.NoError(t, err)
+	assert.Equal(t, []assignment{
+		{key: "a.b", path: []interface{}{"a", "b"}, value: "1"},
+		{key: "c[1].d", path: []interface{}{"c", 1, "d"}, value: "x"},
+		{key: "e", path: []interface{}{"e"}, list: []string{"x", "y,z"}},
+		{key: "f.g", path: []interface{}{"f.g"}, value: "h,i"},
+	}, assignments)
+
+	for _, invalid := range []string{"a", "a..b=1", "a.=1", "[0]=1", "a[x]=1", "a[0]b=1", "=1"} {
+		_, err := parseAssignments(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	values := filepath.Join(dir, "values.yaml")
+	assert.NoError(t, os.WriteFile(values, []byte("cluster:\n  name: from-values\n  region: eu\n"), 0o644))
+	cert := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, os.WriteFile(cert, []byte("CERT"), 0o644))
+
+	s := &Subst{
+		Substitutions: map[string]interface{}{
+			"cluster": map[string]interface{}{"name": "a", "zones": []interface{}{"a", "b"}},
+		},
+		Provenance: Provenance{},
+		Config: config.Configuration{
+			Values:    []string{values},
+			Set:       []string{"cluster.name=foo,cluster.zones[1]=c,app.replicas=3,app.enabled=true"},
+			SetString: []string{"app.version=010"},
+			SetFile:   []string{"tls.ca=" + cert},
+		},
+	}
+	assert.NoError(t, s.loadOverrides())
+	assert.Equal(t, map[string]interface{}{
+		"cluster": map[string]interface{}{"name": "foo", "region": "eu", "zones": []interface{}{"a", "c"}},
+		"app":     map[string]interface{}{"replicas": 3, "enabled": true, "version": "010"},
+		"tls":     map[string]interface{}{"ca": "CERT"},
+	}, s.Substitutions)
+	assert.Equal(t, []Source{{Name: values}, {Name: "--set cluster.name"}}, s.Provenance.Sources("cluster.name"))
+
+	s.Config = config.Configuration{Set: []string{"a..b=1"}}
+	assert.ErrorContains(t, s.loadOverrides(), `invalid --set "a..b=1"`)
+}
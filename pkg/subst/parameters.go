@@ -0,0 +1,254 @@
+package subst
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ParametersVariable is the environment variable ArgoCD passes the plugin parameters in
+	ParametersVariable = "ARGOCD_APP_PARAMETERS"
+	// ParamsNamespace is the key of the plugin parameters in the substitutions
+	ParamsNamespace = "params"
+)
+
+// Parameter is a plugin parameter of an ArgoCD application. Only one of String, Array and Map is set.
+type Parameter struct {
+	Name   string            `json:"name"`
+	String *string           `json:"string,omitempty"`
+	Array  []string          `json:"array,omitempty"`
+	Map    map[string]string `json:"map,omitempty"`
+}
+
+// ParameterAnnouncement describes a parameter for the ArgoCD UI (parameters.dynamic of the plugin)
+type ParameterAnnouncement struct {
+	Name           string            `json:"name"`
+	Title          string            `json:"title,omitempty"`
+	Tooltip        string            `json:"tooltip,omitempty"`
+	CollectionType string            `json:"collectionType,omitempty"`
+	String         string            `json:"string,omitempty"`
+	Array          []string          `json:"array,omitempty"`
+	Map            map[string]string `json:"map,omitempty"`
+}
+
+// mapKey matches map keys of parameters, which do not need to be escaped
+var mapKey = regexp.MustCompile(`^[^.\[\]\\]+$`)
+
+// loadParameters decodes the plugin parameters into .params. Parameters named like a top-level key
+// of the substitutions also override its values. Values replacing a string stay strings, all others are parsed as YAML.
+func (s *Subst) loadParameters() error {
+	content := os.Getenv(ParametersVariable)
+	if content == "" {
+		return nil
+	}
+	var parameters []Parameter
+	if err := json.Unmarshal([]byte(content), &parameters); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", ParametersVariable, err)
+	}
+
+	params := map[string]interface{}{}
+	for _, p := range parameters {
+		params[p.Name] = p.value()
+	}
+	s.merge(map[string]interface{}{ParamsNamespace: params}, Source{Name: "env:" + ParametersVariable})
+
+	for _, p := range parameters {
+		if _, ok := s.Substitutions[p.Name]; !ok || !announced(p.Name) {
+			continue
+		}
+		source := Source{Name: "param:" + p.Name}
+		switch {
+		case p.Map != nil:
+			for _, key := range slices.Sorted(maps.Keys(p.Map)) {
+				path, err := parseKey(key)
+				if err != nil {
+					return fmt.Errorf("invalid key %q of parameter %s: %w", key, p.Name, err)
+				}
+				path = append([]interface{}{p.Name}, path...)
+				data, err := setPath(nil, s.Substitutions, path, parameterValue(p.Map[key], lookupPath(s.Substitutions, path)))
+				if err != nil {
+					return fmt.Errorf("invalid key %q of parameter %s: %w", key, p.Name, err)
+				}
+				s.merge(data.(map[string]interface{}), source)
+			}
+		case p.Array != nil:
+			current, _ := s.Substitutions[p.Name].([]interface{})
+			items := make([]interface{}, 0, len(p.Array))
+			for i, item := range p.Array {
+				var value interface{}
+				if i < len(current) {
+					value = current[i]
+				}
+				items = append(items, parameterValue(item, value))
+			}
+			s.merge(map[string]interface{}{p.Name: items}, source)
+		case p.String != nil:
+			s.merge(map[string]interface{}{p.Name: parameterValue(*p.String, s.Substitutions[p.Name])}, source)
+		}
+	}
+	return nil
+}
+
+// parameterValue types a parameter value like the value it replaces. Strings stay strings (eg. "3" or "true"),
+// other values are parsed as YAML. Empty values of null stay null, as they are announced empty.
+func parameterValue(value string, current interface{}) interface{} {
+	switch current.(type) {
+	case string:
+		return value
+	case nil:
+		if value == "" {
+			return nil
+		}
+	}
+	return yamlValue(value)
+}
+
+// lookupPath returns the value at path (map keys and list indexes), nil if it does not exist
+func lookupPath(value interface{}, path []interface{}) interface{} {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			k, ok := key.(string)
+			if !ok {
+				return nil
+			}
+			value = v[k]
+		case []interface{}:
+			i, ok := key.(int)
+			if !ok || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// value returns the value of the parameter as string, list or map
+func (p Parameter) value() interface{} {
+	switch {
+	case p.Map != nil:
+		m := make(map[string]interface{}, len(p.Map))
+		for k, v := range p.Map {
+			m[k] = v
+		}
+		return m
+	case p.Array != nil:
+		l := make([]interface{}, 0, len(p.Array))
+		for _, v := range p.Array {
+			l = append(l, v)
+		}
+		return l
+	case p.String != nil:
+		return *p.String
+	}
+	return ""
+}
+
+// Parameters announces the top-level keys of the substitutions as parameters. Secrets and the keys
+// provided by ArgoCD (.argocd, .params) are not announced. Maps are announced with flattened keys (eg. app.replicas).
+func (s *Subst) Parameters() []ParameterAnnouncement {
+	announcements := []ParameterAnnouncement{}
+	for _, key := range slices.Sorted(maps.Keys(s.Substitutions)) {
+		if !announced(key) || s.secret(key) {
+			continue
+		}
+		a := ParameterAnnouncement{Name: key, Title: key}
+		if sources := s.Provenance.Sources(key); len(sources) > 0 {
+			name := sources[len(sources)-1].Name
+			if rel, err := filepath.Rel(s.Config.RootDirectory, name); err == nil && filepath.IsAbs(name) {
+				name = rel
+			}
+			a.Tooltip = "From " + name
+		}
+		switch value := s.Substitutions[key].(type) {
+		case map[string]interface{}:
+			a.CollectionType = "map"
+			a.Map = map[string]string{}
+			flatten(a.Map, "", value)
+		case []interface{}:
+			a.CollectionType = "array"
+			a.Array = make([]string, 0, len(value))
+			for _, item := range value {
+				a.Array = append(a.Array, parameterString(item))
+			}
+		default:
+			a.CollectionType = "string"
+			a.String = parameterString(value)
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements
+}
+
+// announced reports whether a top-level key can be a parameter
+func announced(key string) bool {
+	switch key {
+	case ArgoCDNamespace, ParamsNamespace, "ejson", "sops":
+		return false
+	}
+	return true
+}
+
+// secret reports whether any value below the top-level key was decrypted
+func (s *Subst) secret(key string) bool {
+	for path, sources := range s.Provenance {
+		if path != key && !strings.HasPrefix(path, key+".") {
+			continue
+		}
+		for _, source := range sources {
+			if source.Secret {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// flatten adds all leaf values of value to m, keyed by path (eg. app.replicas, zones[0])
+func flatten(m map[string]string, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if !mapKey.MatchString(key) {
+				key = strings.NewReplacer(`\`, `\\`, ".", `\.`, "[", `\[`, "]", `\]`).Replace(key)
+			}
+			if path != "" {
+				key = path + "." + key
+			}
+			flatten(m, key, item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flatten(m, path+"["+strconv.Itoa(i)+"]", item)
+		}
+	default:
+		m[path] = parameterString(value)
+	}
+}
+
+// parameterString formats a value as parameter string, which is parsed as YAML again
+func parameterString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		out, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(out)
+	}
+	return fmt.Sprint(value)
+}
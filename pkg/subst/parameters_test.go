@@ -0,0 +1,104 @@
+package subst
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadParameters(t *testing.T) {
+	t.Setenv(ParametersVariable, `[
+		{"name": "settings", "map": {"app.replicas": "3", "zones[1]": "c"}},
+		{"name": "enabled", "string": "true"},
+		{"name": "extra", "array": ["a", "b"]}
+	]`)
+
+	s := &Subst{
+		Substitutions: map[string]interface{}{
+			"settings": map[string]interface{}{
+				"app":   map[string]interface{}{"name": "app", "replicas": 1},
+				"zones": []interface{}{"a", "b"},
+			},
+			"enabled": false,
+		},
+		Provenance: Provenance{},
+	}
+	assert.NoError(t, s.loadParameters())
+	assert.Equal(t, map[string]interface{}{
+		"settings": map[string]interface{}{
+			"app":   map[string]interface{}{"name": "app", "replicas": 3},
+			"zones": []interface{}{"a", "c"},
+		},
+		"enabled": true,
+		"params": map[string]interface{}{
+			"settings": map[string]interface{}{"app.replicas": "3", "zones[1]": "c"},
+			"enabled":  "true",
+			"extra":    []interface{}{"a", "b"},
+		},
+	}, s.Substitutions)
+
+	t.Setenv(ParametersVariable, `{`)
+	assert.ErrorContains(t, s.loadParameters(), "failed to decode ARGOCD_APP_PARAMETERS")
+}
+
+func TestParameters(t *testing.T) {
+	s := &Subst{Substitutions: map[string]interface{}{}, Provenance: Provenance{}}
+	s.merge(map[string]interface{}{
+		"settings": map[string]interface{}{
+			"app":   map[string]interface{}{"replicas": 3},
+			"zones": []interface{}{"a"},
+			"a.b":   "c",
+		},
+		"name":  "app",
+		"hosts": []interface{}{"a", map[string]interface{}{"b": 1}},
+	}, Source{Name: "subst.yaml"})
+	s.merge(map[string]interface{}{"ejson": map[string]interface{}{"password": "secret"}}, Source{Name: "secret.ejson", Secret: true})
+	s.merge(map[string]interface{}{"token": "secret"}, Source{Name: "secret.ejson", Secret: true})
+
+	assert.Equal(t, []ParameterAnnouncement{
+		{Name: "hosts", Title: "hosts", Tooltip: "From subst.yaml", CollectionType: "array", Array: []string{"a", `{"b":1}`}},
+		{Name: "name", Title: "name", Tooltip: "From subst.yaml", CollectionType: "string", String: "app"},
+		{Name: "settings", Title: "settings", CollectionType: "map", Map: map[string]string{
+			"app.replicas": "3",
+			"zones[0]":     "a",
+			`a\.b`:         "c",
+		}},
+	}, s.Parameters())
+}
+
+func TestParametersRoundTrip(t *testing.T) {
+	substitutions := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"app":     map[string]interface{}{"replicas": 3, "version": "1.10", "enabled": "true"},
+			"zones":   []interface{}{"a", "007"},
+			"timeout": nil,
+		},
+		"port":    "8080",
+		"enabled": true,
+		"hosts":   []interface{}{"a", 1, map[string]interface{}{"b": "2"}},
+	}
+	s := &Subst{Substitutions: map[string]interface{}{}, Provenance: Provenance{}}
+	s.merge(deepCopy(substitutions).(map[string]interface{}), Source{Name: "subst.yaml"})
+
+	var parameters []Parameter
+	for _, a := range s.Parameters() {
+		p := Parameter{Name: a.Name}
+		switch a.CollectionType {
+		case "map":
+			p.Map = a.Map
+		case "array":
+			p.Array = a.Array
+		default:
+			p.String = &a.String
+		}
+		parameters = append(parameters, p)
+	}
+	content, err := json.Marshal(parameters)
+	assert.NoError(t, err)
+	t.Setenv(ParametersVariable, string(content))
+
+	assert.NoError(t, s.loadParameters())
+	delete(s.Substitutions, ParamsNamespace)
+	assert.Equal(t, substitutions, s.Substitutions)
+}
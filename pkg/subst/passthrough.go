@@ -0,0 +1,81 @@
+package subst
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PassthroughAnnotation marks resources, which are not templated (eg. with embedded Prometheus or Helm templates)
+const PassthroughAnnotation = "subst.kubelize.io/passthrough"
+
+// passthrough checks if a resource is not templated, because it has the passthrough annotation
+// or its origin matches a passthrough pattern. The annotation is removed from the returned YAML.
+func (s *Subst) passthrough(origin string, content []byte) ([]byte, bool, error) {
+	if s.matchesPassthrough(origin) {
+		return content, true, nil
+	}
+	if !strings.Contains(string(content), PassthroughAnnotation) {
+		return content, false, nil
+	}
+
+	node, err := kyaml.Parse(string(content))
+	if err != nil {
+		return nil, false, err
+	}
+	if node.GetAnnotations()[PassthroughAnnotation] != "true" {
+		return content, false, nil
+	}
+	if _, err := node.Pipe(kyaml.ClearAnnotation(PassthroughAnnotation)); err != nil {
+		return nil, false, err
+	}
+	// Remove the annotations field, if the passthrough annotation was the only one
+	if len(node.GetAnnotations()) == 0 {
+		if _, err := node.Pipe(kyaml.Lookup(kyaml.MetadataField), kyaml.Clear(kyaml.AnnotationsField)); err != nil {
+			return nil, false, err
+		}
+	}
+	out, err := node.String()
+	return []byte(out), true, err
+}
+
+// passthroughSource checks if a source file of the pre-build templating is not templated,
+// because it matches a passthrough pattern or all of its resources have the passthrough annotation
+func (s *Subst) passthroughSource(file string, content []byte) bool {
+	if rel, err := filepath.Rel(s.Config.RootDirectory, file); err == nil && s.matchesPassthrough(filepath.ToSlash(rel)) {
+		return true
+	}
+	if !strings.Contains(string(content), PassthroughAnnotation) {
+		return false
+	}
+	nodes, err := kio.FromBytes(content)
+	if err != nil || len(nodes) == 0 {
+		return false
+	}
+	for _, node := range nodes {
+		if node.GetAnnotations()[PassthroughAnnotation] != "true" {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesPassthrough reports whether file (relative to the root directory) matches one of the passthrough patterns.
+// Patterns are matched against the path and all of its trailing sub paths (eg. alerts/*.yaml matches ../base/alerts/node.yaml).
+func (s *Subst) matchesPassthrough(file string) bool {
+	if file == "" {
+		return false
+	}
+	segments := strings.Split(path.Clean(file), "/")
+	for _, pattern := range s.Config.Passthrough {
+		for i := range segments {
+			if matched, _ := path.Match(pattern, strings.Join(segments[i:], "/")); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
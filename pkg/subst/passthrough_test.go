@@ -0,0 +1,46 @@
+package subst
+
+import (
+	"testing"
+
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesPassthrough(t *testing.T) {
+	s := &Subst{Config: config.Configuration{Passthrough: []string{"alerts/*.yaml", "*-dashboard.json"}}}
+
+	assert.True(t, s.matchesPassthrough("alerts/node.yaml"))
+	assert.True(t, s.matchesPassthrough("../base/alerts/node.yaml"))
+	assert.True(t, s.matchesPassthrough("dashboards/app-dashboard.json"))
+	assert.False(t, s.matchesPassthrough("deployment.yaml"))
+	assert.False(t, s.matchesPassthrough(""))
+}
+
+func TestPassthroughAnnotation(t *testing.T) {
+	s := &Subst{}
+
+	content := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dashboard
+  annotations:
+    subst.kubelize.io/passthrough: "true"
+data:
+  panel: '{{ .grafana }}'
+`)
+	out, passthrough, err := s.passthrough("dashboard.yaml", content)
+	assert.NoError(t, err)
+	assert.True(t, passthrough)
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dashboard
+data:
+  panel: '{{ .grafana }}'
+`, string(out))
+
+	_, passthrough, err = s.passthrough("configmap.yaml", []byte("kind: ConfigMap\nmetadata:\n  name: app\n"))
+	assert.NoError(t, err)
+	assert.False(t, passthrough)
+}
@@ -0,0 +1,132 @@
+package subst
+
+import (
+	"slices"
+
+	"github.com/kubelize/subst/internal/utils"
+)
+
+// Source describes where a substitution value was loaded from
+type Source struct {
+	// Name of the file or environment variable
+	Name string
+	// Secret is set for decrypted values
+	Secret bool
+}
+
+// Provenance tracks the sources of every leaf value in the substitutions, by dotted path.
+// The last source of a path defined the current value, all previous sources were overridden.
+type Provenance map[string][]Source
+
+// Sources returns the sources of the value at the given dotted path
+func (p Provenance) Sources(path string) []Source {
+	return p[path]
+}
+
+// record registers source for all leaf values of value at path, previous is the value it replaces
+func (p Provenance) record(path string, value interface{}, previous interface{}, source Source) {
+	p.recordWithHistory(path, value, previous, source, nil)
+}
+
+func (p Provenance) recordWithHistory(path string, value interface{}, previous interface{}, source Source, inherited []Source) {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		// A map replacing a leaf, passes the history of the leaf to its children
+		previousMap, _ := previous.(map[string]interface{})
+		if sources, exists := p[path]; exists && len(previousMap) == 0 {
+			inherited = appendSources(slices.Clone(inherited), sources...)
+			delete(p, path)
+		}
+		for key, v := range m {
+			p.recordWithHistory(joinPath(path, key), v, previousMap[key], source, inherited)
+		}
+		return
+	}
+
+	// Collect previous values at this path (replaced leaf) and below it (replaced map)
+	history := appendSources(slices.Clone(inherited), p[path]...)
+	delete(p, path)
+	history = p.removeBelow(path, previous, history)
+	p[path] = appendSources(history, source)
+}
+
+// removeBelow removes the sources of all leaves of the replaced value at path and appends them to history
+func (p Provenance) removeBelow(path string, previous interface{}, history []Source) []Source {
+	m, ok := previous.(map[string]interface{})
+	if !ok {
+		return history
+	}
+	for key, v := range m {
+		child := joinPath(path, key)
+		history = appendSources(history, p[child]...)
+		delete(p, child)
+		history = p.removeBelow(child, v, history)
+	}
+	return history
+}
+
+// minSecretLength is the minimum length of decrypted values, which are redacted wherever they are copied to.
+// Shorter values would match unrelated values.
+const minSecretLength = 4
+
+// Secrets returns the decrypted values of all secret files, which were encrypted
+func (s *Subst) Secrets() []string {
+	return s.secrets
+}
+
+// IsSecret reports whether value is equal to a decrypted secret value. Values copied from secrets
+// into other paths (eg. with spruce operators) are not tracked by their source, but still detected.
+func (s *Subst) IsSecret(value interface{}) bool {
+	str, ok := value.(string)
+	return ok && slices.Contains(s.secrets, str)
+}
+
+// addSecrets adds the string values of decrypted, which were encrypted (differ from encrypted at the same path).
+// Plain values (eg. ejson keys starting with _ or sops unencrypted suffixes) are not added.
+func (s *Subst) addSecrets(decrypted interface{}, encrypted interface{}) {
+	switch v := decrypted.(type) {
+	case map[string]interface{}:
+		encryptedMap, _ := encrypted.(map[string]interface{})
+		for key, item := range v {
+			s.addSecrets(item, encryptedMap[key])
+		}
+	case []interface{}:
+		encryptedList, _ := encrypted.([]interface{})
+		for i, item := range v {
+			var encryptedItem interface{}
+			if i < len(encryptedList) {
+				encryptedItem = encryptedList[i]
+			}
+			s.addSecrets(item, encryptedItem)
+		}
+	case string:
+		if plain, ok := encrypted.(string); (ok && plain == v) || len(v) < minSecretLength || slices.Contains(s.secrets, v) {
+			return
+		}
+		s.secrets = append(s.secrets, v)
+	}
+}
+
+// merge deep merges data from source into the substitutions
+func (s *Subst) merge(data map[string]interface{}, source Source) {
+	for key, value := range data {
+		s.Provenance.record(key, value, s.Substitutions[key], source)
+	}
+	s.Substitutions = utils.DeepMerge(s.Substitutions, data)
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// appendSources appends sources, which are not yet part of the list
+func appendSources(list []Source, sources ...Source) []Source {
+	for _, source := range sources {
+		if !slices.Contains(list, source) {
+			list = append(list, source)
+		}
+	}
+	return list
+}
@@ -0,0 +1,57 @@
+package subst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	s := &Subst{Substitutions: map[string]interface{}{}, Provenance: Provenance{}}
+	base := Source{Name: "base/subst.yaml"}
+	overlay := Source{Name: "overlay/subst.yaml"}
+
+	s.merge(map[string]interface{}{
+		"app":     map[string]interface{}{"name": "app", "replicas": 1},
+		"cluster": "a",
+	}, base)
+	s.merge(map[string]interface{}{
+		"app":     map[string]interface{}{"replicas": 3},
+		"cluster": map[string]interface{}{"name": "b"},
+	}, overlay)
+
+	assert.Equal(t, []Source{base}, s.Provenance.Sources("app.name"))
+	assert.Equal(t, []Source{base, overlay}, s.Provenance.Sources("app.replicas"))
+	assert.Equal(t, []Source{base, overlay}, s.Provenance.Sources("cluster.name"))
+	assert.Nil(t, s.Provenance.Sources("cluster"))
+
+	s.merge(map[string]interface{}{"app": "replaced"}, overlay)
+	assert.Equal(t, []Source{base, overlay}, s.Provenance.Sources("app"))
+	assert.Nil(t, s.Provenance.Sources("app.name"))
+}
+
+func TestIsSecret(t *testing.T) {
+	s := &Subst{}
+	s.addSecrets(map[string]interface{}{
+		"_public_key": "9474413baa1422b6",
+		"data":        map[string]interface{}{"password": "hunter2", "pin": "123", "empty": ""},
+		"replicas":    3,
+		"enabled":     true,
+		"hosts":       []interface{}{"db.internal", "plain.example.com"},
+	}, map[string]interface{}{
+		"_public_key": "9474413baa1422b6",
+		"data":        map[string]interface{}{"password": "EJ[1:abc]", "pin": "EJ[1:def]", "empty": ""},
+		"replicas":    "ENC[AES256_GCM,data:Mw==,type:int]",
+		"enabled":     "ENC[AES256_GCM,data:dHJ1ZQ==,type:bool]",
+		"hosts":       []interface{}{"ENC[AES256_GCM,data:ZGI=,type:str]", "plain.example.com"},
+	})
+
+	assert.ElementsMatch(t, []string{"hunter2", "db.internal"}, s.Secrets())
+	assert.True(t, s.IsSecret("hunter2"))
+	assert.False(t, s.IsSecret("postgres://app:hunter2@db"))
+	assert.False(t, s.IsSecret("9474413baa1422b6"))
+	assert.False(t, s.IsSecret("123"))
+	assert.False(t, s.IsSecret(3))
+	assert.False(t, s.IsSecret("true"))
+	assert.False(t, s.IsSecret(nil))
+}
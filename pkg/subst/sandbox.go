@@ -0,0 +1,56 @@
+package subst
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubelize/subst/internal/wrapper"
+)
+
+// Sandbox modes of the configuration
+const (
+	SandboxAuto = "auto"
+	SandboxOn   = "true"
+	SandboxOff  = "false"
+)
+
+// sandbox returns the sandbox for templates, if enabled. With auto (default) templates are sandboxed,
+// if subst runs as ArgoCD plugin.
+func (s *Subst) sandbox() (*wrapper.Sandbox, error) {
+	switch strings.ToLower(s.Config.Sandbox) {
+	case SandboxOn:
+	case SandboxOff:
+		return nil, nil
+	case SandboxAuto, "":
+		if os.Getenv("ARGOCD_APP_NAME") == "" {
+			return nil, nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid sandbox mode %q, must be one of %s, %s, %s", s.Config.Sandbox, SandboxAuto, SandboxOn, SandboxOff)
+	}
+	return &wrapper.Sandbox{Root: s.repositoryRoot(), Dir: s.Config.RootDirectory}, nil
+}
+
+// repositoryRoot returns the root of the repository containing the root directory: the directory with .git,
+// the root directory without the source path of the ArgoCD application or the root directory itself
+func (s *Subst) repositoryRoot() string {
+	root := s.Config.RootDirectory
+	for dir := root; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	// ArgoCD renders the source path of the application in a copy of the repository
+	if sourcePath := filepath.Clean(os.Getenv("ARGOCD_APP_SOURCE_PATH")); sourcePath != "." && !filepath.IsAbs(sourcePath) {
+		if repository, ok := strings.CutSuffix(root, string(filepath.Separator)+sourcePath); ok {
+			return repository
+		}
+	}
+	return root
+}
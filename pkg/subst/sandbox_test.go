@@ -0,0 +1,87 @@
+package subst
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxMode(t *testing.T) {
+	tests := []struct {
+		mode      string
+		appName   string
+		sandboxed bool
+		err       bool
+	}{
+		{mode: "", sandboxed: false},
+		{mode: "", appName: "app", sandboxed: true},
+		{mode: SandboxAuto, sandboxed: false},
+		{mode: SandboxAuto, appName: "app", sandboxed: true},
+		{mode: SandboxOn, sandboxed: true},
+		{mode: "TRUE", sandboxed: true},
+		{mode: SandboxOff, appName: "app", sandboxed: false},
+		{mode: "yes", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.mode+"/"+test.appName, func(t *testing.T) {
+			t.Setenv("ARGOCD_APP_NAME", test.appName)
+			t.Setenv("ARGOCD_APP_SOURCE_PATH", "")
+			root := t.TempDir()
+
+			s := &Subst{Config: config.Configuration{RootDirectory: root, Sandbox: test.mode}}
+			sandbox, err := s.sandbox()
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if !test.sandboxed {
+				assert.Nil(t, sandbox)
+				return
+			}
+			if assert.NotNil(t, sandbox) {
+				assert.Equal(t, root, sandbox.Root)
+				assert.Equal(t, root, sandbox.Dir)
+			}
+		})
+	}
+}
+
+func TestRepositoryRoot(t *testing.T) {
+	tmp := t.TempDir()
+	repository := filepath.Join(tmp, "repository")
+	checkout := filepath.Join(tmp, "checkout")
+	if err := os.MkdirAll(filepath.Join(repository, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		root       string
+		sourcePath string
+		expected   string
+	}{
+		{"git directory", filepath.Join(repository, "clusters", "cluster-01"), "", repository},
+		{"git directory before source path", filepath.Join(repository, "clusters", "cluster-01"), "cluster-01", repository},
+		{"git directory is root", repository, "", repository},
+		{"source path", filepath.Join(checkout, "clusters", "cluster-01"), "clusters/cluster-01", checkout},
+		{"source path with trailing slash", filepath.Join(checkout, "clusters", "cluster-01"), "clusters/cluster-01/", checkout},
+		{"source path not matching", filepath.Join(checkout, "clusters", "cluster-01"), "clusters/cluster-02", filepath.Join(checkout, "clusters", "cluster-01")},
+		{"source path matching part of a directory", filepath.Join(checkout, "clusters", "cluster-01"), "01", filepath.Join(checkout, "clusters", "cluster-01")},
+		{"absolute source path", filepath.Join(checkout, "clusters", "cluster-01"), "/clusters/cluster-01", filepath.Join(checkout, "clusters", "cluster-01")},
+		{"root source path", filepath.Join(checkout, "clusters"), ".", filepath.Join(checkout, "clusters")},
+		{"no source path", filepath.Join(checkout, "clusters"), "", filepath.Join(checkout, "clusters")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("ARGOCD_APP_NAME", "app")
+			t.Setenv("ARGOCD_APP_SOURCE_PATH", test.sourcePath)
+
+			s := &Subst{Config: config.Configuration{RootDirectory: test.root}}
+			assert.Equal(t, test.expected, s.repositoryRoot())
+		})
+	}
+}
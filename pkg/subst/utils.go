@@ -0,0 +1,73 @@
+package subst
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var invalidKeyCharacters = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// namespaceKey derives the key for the content of a file from its name,
+// so it can be accessed in templates (eg. app-secret.ejson -> app_secret).
+// The first matching suffix is removed, otherwise the extension (eg. app.sops.yaml -> app)
+func namespaceKey(file string, suffixes ...string) string {
+	name := filepath.Base(file)
+	trimmed := strings.TrimSuffix(name, filepath.Ext(name))
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			trimmed = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+	name = trimmed
+	key := invalidKeyCharacters.ReplaceAllString(name, "_")
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		key = "_" + key
+	}
+	return key
+}
+
+// hasSuffix reports whether name ends with one of the suffixes and is not only the suffix
+// (eg. .sops.yaml is the sops configuration, not an encrypted file)
+func hasSuffix(name string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) && name != suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// sopsAgeKeyFiles returns the age key files for sops, the configured files first,
+// followed by SOPS_AGE_KEY_FILE and the default locations
+func sopsAgeKeyFiles(configured []string) []string {
+	files := slices.Clone(configured)
+	if file := os.Getenv("SOPS_AGE_KEY_FILE"); file != "" {
+		files = append(files, file)
+	}
+	// Prefer /opt/sops/age/keys.txt (for containers), fall back to the sops user config
+	files = append(files, "/opt/sops/age/keys.txt")
+	if configDir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(configDir, "sops", "age", "keys.txt"))
+	}
+	return files
+}
+
+// isYAMLFile reports whether file has a YAML extension
+func isYAMLFile(file string) bool {
+	return hasSuffix(file, ".yaml", ".yml")
+}
+
+// yamlValue parses a value as YAML (eg. 3 is an integer), invalid YAML stays a string
+func yamlValue(value string) interface{} {
+	var typed interface{}
+	if err := yaml.Unmarshal([]byte(value), &typed); err != nil || typed == nil {
+		return value
+	}
+	return typed
+}
@@ -0,0 +1,25 @@
+package subst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceKey(t *testing.T) {
+	tests := []struct {
+		file     string
+		suffixes []string
+		key      string
+	}{
+		{"app-secret.ejson", []string{".ejson"}, "app_secret"},
+		{"overlay/app.secret.ejson", []string{".ejson"}, "app_secret"},
+		{"app.sops.yaml", []string{".sops.yaml", ".sops.yml", ".sops.json"}, "app"},
+		{"app.sops.json", []string{".sops.yaml", ".sops.yml", ".sops.json"}, "app"},
+		{"db.json", []string{".ejson"}, "db"},
+		{"01-secret.ejson", []string{".ejson"}, "_01_secret"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.key, namespaceKey(test.file, test.suffixes...), test.file)
+	}
+}
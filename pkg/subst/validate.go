@@ -0,0 +1,87 @@
+package subst
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kubelize/subst/internal/utils"
+	"github.com/kubelize/subst/internal/validate"
+	"github.com/rs/zerolog/log"
+)
+
+// Validate validates the rendered manifests against the bundled Kubernetes schemas, the schemas in
+// the configured schema directories and the CRDs of the build output. Violations are reported per resource.
+func (s *Subst) Validate() error {
+	validator, err := validate.NewValidator(s.Config.SchemaDir...)
+	if err != nil {
+		return fmt.Errorf("failed to load schemas: %w", err)
+	}
+
+	// Parse all manifests first, so CRDs of the build output are known for all resources
+	var objects []map[string]interface{}
+	var sources []string
+	for i, manifest := range s.Manifests {
+		documents, err := utils.UnmarshalYAMLDocuments(manifest)
+		if err != nil {
+			return fmt.Errorf("failed to parse rendered manifest: %w", err)
+		}
+		for _, document := range documents {
+			object, ok := document.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if validate.IsCRD(object) {
+				if err := validator.AddCRD(object); err != nil {
+					return err
+				}
+			}
+			objects = append(objects, object)
+			sources = append(sources, s.source(i))
+		}
+	}
+
+	var errs []error
+	for i, object := range objects {
+		err := validator.Validate(object)
+		if errors.Is(err, validate.ErrNoSchema) {
+			log.Warn().Msgf("Skipping validation of %s: %v", objectName(object), err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, objectError(object, sources[i], err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("validation failed for %d of %d resource(s):\n%w", len(errs), len(objects), errors.Join(errs...))
+	}
+
+	log.Debug().Msgf("Validated %d resource(s)", len(objects))
+	return nil
+}
+
+// source returns the origin file of the manifest at index i
+func (s *Subst) source(i int) string {
+	if i < len(s.Sources) {
+		return s.Sources[i]
+	}
+	return ""
+}
+
+// objectName identifies a rendered object as kind namespace/name
+func objectName(object map[string]interface{}) string {
+	kind, _ := object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if namespace, _ := metadata["namespace"].(string); namespace != "" {
+		return fmt.Sprintf("%s %s/%s", kind, namespace, name)
+	}
+	return fmt.Sprintf("%s %s", kind, name)
+}
+
+// objectError attributes an error to a rendered object and its origin file
+func objectError(object map[string]interface{}, source string, err error) error {
+	if source == "" {
+		return fmt.Errorf("%s: %w", objectName(object), err)
+	}
+	return fmt.Errorf("%s (%s): %w", objectName(object), source, err)
+}
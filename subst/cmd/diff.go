@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/kubelize/subst/internal/diff"
+	"github.com/kubelize/subst/internal/utils"
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/kubelize/subst/pkg/subst"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff --base <ref> [dir]",
+		Short: "Show the changes of the rendered resources compared to a git revision",
+		Long: heredoc.Doc(`
+			Run 'subst diff' to render the directory in the working tree and at the base git revision
+			and print the changed fields of each resource. Resources are matched by group, version, kind,
+			namespace and name. Values of Secrets and values equal to decrypted secrets are redacted.`),
+		Example: `# Show the changes of the local directory compared to main
+subst diff --base main
+# Show the changes of an overlay compared to the previous commit
+subst diff --base HEAD~1 clusters/cluster-01`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: diffRun,
+	}
+
+	flags := cmd.Flags()
+	addCommonFlags(flags)
+	addSubstitutionFlags(flags)
+	addBuildFlags(flags)
+	flags.String("base", "", heredoc.Doc(`
+	        Git revision to compare the working tree with (eg. main, HEAD~1)`))
+	if err := cmd.MarkFlagRequired("base"); err != nil {
+		panic(err)
+	}
+	return cmd
+}
+
+func diffRun(cmd *cobra.Command, args []string) error {
+	dir, err := rootDirectory(args)
+	if err != nil {
+		return err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, dir)
+	if err != nil {
+		return fmt.Errorf("failed loading configuration: %w", err)
+	}
+
+	base, err := cmd.Flags().GetString("base")
+	if err != nil {
+		return err
+	}
+
+	head, secrets, err := renderResources(*configuration)
+	if err != nil {
+		return err
+	}
+
+	worktree, relative, cleanup, err := gitWorktree(configuration.RootDirectory, base)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// The base revision uses the same configuration, only the root directory is replaced
+	baseConfiguration := *configuration
+	baseConfiguration.RootDirectory = filepath.Join(worktree, relative)
+
+	var baseResources []diff.Resource
+	if _, err := os.Stat(baseConfiguration.RootDirectory); errors.Is(err, os.ErrNotExist) {
+		log.Info().Msgf("Directory %s does not exist at %s", relative, base)
+	} else {
+		var baseSecrets []string
+		baseResources, baseSecrets, err = renderResources(baseConfiguration)
+		if err != nil {
+			return fmt.Errorf("failed to render %s: %w", base, err)
+		}
+		secrets = append(secrets, baseSecrets...)
+	}
+
+	printDiff(diff.Resources(baseResources, head, secrets))
+	return nil
+}
+
+// renderResources builds the substitutions and renders all resources of the configuration.
+// The decrypted secret values are returned to redact them in the diff.
+func renderResources(configuration config.Configuration) ([]diff.Resource, []string, error) {
+	m, err := subst.NewSubst(configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := m.Build(); err != nil {
+		return nil, nil, err
+	}
+
+	var resources []diff.Resource
+	for _, manifest := range m.Manifests {
+		documents, err := utils.UnmarshalYAMLDocuments(manifest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse rendered manifest: %w", err)
+		}
+		for _, document := range documents {
+			object, ok := document.(map[string]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("rendered document is not an object: %v", document)
+			}
+			resources = append(resources, diff.NewResource(object))
+		}
+	}
+	return resources, m.Secrets(), nil
+}
+
+func printDiff(diffs []diff.ResourceDiff) {
+	for _, d := range diffs {
+		fmt.Printf("%s %s\n", d.Type, d.Resource)
+		for _, field := range d.Fields {
+			switch field.Type {
+			case diff.Added:
+				fmt.Printf("    %s %s: %s\n", field.Type, field.Path, diff.Format(field.Head))
+			case diff.Removed:
+				fmt.Printf("    %s %s: %s\n", field.Type, field.Path, diff.Format(field.Base))
+			default:
+				fmt.Printf("    %s %s: %s -> %s\n", field.Type, field.Path, diff.Format(field.Base), diff.Format(field.Head))
+			}
+		}
+	}
+}
+
+// gitWorktree checks out ref into a temporary git worktree of the repository containing dir.
+// It returns the worktree, the path of dir relative to the repository and a function removing the worktree.
+func gitWorktree(dir string, ref string) (worktree string, relative string, cleanup func(), err error) {
+	toplevel, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", "", nil, err
+	}
+	relative, err = filepath.Rel(toplevel, realDir)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	worktree, err = os.MkdirTemp("", "subst-diff-")
+	if err != nil {
+		return "", "", nil, err
+	}
+	cleanup = func() {
+		if _, err := git(toplevel, "worktree", "remove", "--force", worktree); err != nil {
+			log.Warn().Msgf("Failed to remove worktree %s: %v", worktree, err)
+		}
+		os.RemoveAll(worktree)
+	}
+
+	if _, err := git(toplevel, "worktree", "add", "--detach", worktree, ref); err != nil {
+		os.RemoveAll(worktree)
+		return "", "", nil, fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+	log.Debug().Msgf("Checked out %s to %s", ref, worktree)
+
+	return worktree, relative, cleanup, nil
+}
+
+// git runs a git command in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
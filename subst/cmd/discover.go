@@ -31,10 +31,11 @@ func discover(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	_, err = config.LoadConfiguration(cfgFile, cmd, dir)
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, dir)
 	if err != nil {
 		return fmt.Errorf("failed loading configuration: %w", err)
 	}
+	dir = configuration.RootDirectory
 
 	if hasSubstFiles(dir) {
 		log.Debug().Msg("Found subst.yaml files - subst plugin applicable")
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/kubelize/subst/internal/spruce"
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate repositories to subst templates",
+	}
+	cmd.AddCommand(newMigrateSpruceCmd())
+	return cmd
+}
+
+func newMigrateSpruceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "spruce [dir]",
+		Short: "Convert spruce operators to templates",
+		Long: heredoc.Doc(`
+			Run 'subst migrate spruce' to convert the spruce operators (grab, concat, stringify, join) in all YAML files
+			below the directory to templates. References to $.subst are converted to the substitutions, (( append ))
+			list items are removed and Prometheus and Alertmanager variables (eg. {{ $labels.instance }}) are escaped.
+			Files are edited in place, so formatting and comments are kept. Operators which can not be converted
+			are listed, use --report for a machine-readable report.`),
+		Example: `# Preview the conversion of the local directory
+subst migrate spruce --dry-run
+# Convert a directory and write a report of the remaining operators
+subst migrate spruce --report unconverted.json apps/`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: migrateSpruce,
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("dry-run", false, heredoc.Doc(`
+	        Print a diff of the changes instead of writing the files`))
+	flags.String("report", "", heredoc.Doc(`
+	        Write a JSON report of the conversion and the operators left unconverted to the given file (- for stdout)`))
+	return cmd
+}
+
+// migrationReport is the machine-readable report of a migration
+type migrationReport struct {
+	Files       int                  `json:"files"`
+	Converted   int                  `json:"converted"`
+	Escaped     int                  `json:"escaped"`
+	Unconverted []spruce.Unconverted `json:"unconverted"`
+}
+
+func migrateSpruce(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	reportFile, err := cmd.Flags().GetString("report")
+	if err != nil {
+		return err
+	}
+
+	report := migrationReport{Unconverted: []spruce.Unconverted{}}
+	err = filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if file != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(file, ".yaml") && !strings.HasSuffix(file, ".yml") || entry.Name() == config.ConfigFileName {
+			return nil
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(content, []byte("((")) && !bytes.Contains(content, []byte("{{")) {
+			return nil
+		}
+
+		// Substitution files are not rendered as templates
+		name := entry.Name()
+		templated := name != "subst.yaml" && name != "subst.yml"
+		migration, err := spruce.Migrate(content, templated)
+		if err != nil {
+			if bytes.Contains(content, []byte("((")) {
+				report.Unconverted = append(report.Unconverted, spruce.Unconverted{File: file, Reason: err.Error()})
+			}
+			log.Debug().Msgf("Skipping %s: %v", file, err)
+			return nil
+		}
+		for _, u := range migration.Unconverted {
+			u.File = file
+			report.Unconverted = append(report.Unconverted, u)
+		}
+		if !migration.Changed() {
+			return nil
+		}
+		report.Files++
+		report.Converted += migration.Converted
+		report.Escaped += migration.Escaped
+
+		if dryRun {
+			diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(content)),
+				B:        difflib.SplitLines(string(migration.Content)),
+				FromFile: file,
+				ToFile:   file,
+				Context:  3,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Print(diff)
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("Converted %s", file)
+		return os.WriteFile(file, migration.Content, info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+
+	verb := "Converted"
+	if dryRun {
+		verb = "Would convert"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d operator(s) and escaped %d template variable(s) in %d file(s), %d operator(s) left unconverted\n",
+		verb, report.Converted, report.Escaped, report.Files, len(report.Unconverted))
+	for _, u := range report.Unconverted {
+		if u.Line > 0 {
+			fmt.Fprintf(os.Stderr, "  %s:%d:%d %s: %s\n", u.File, u.Line, u.Column, u.Expression, u.Reason)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", u.File, u.Reason)
+		}
+	}
+
+	if reportFile == "" {
+		return nil
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if reportFile == "-" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return os.WriteFile(reportFile, out, 0o644)
+}
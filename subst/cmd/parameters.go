@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/kubelize/subst/pkg/subst"
+	"github.com/spf13/cobra"
+)
+
+func newParametersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "parameters [dir]",
+		Short: "Announce the substitutions as ArgoCD plugin parameters",
+		Long: heredoc.Doc(`
+			Run 'subst parameters' to print the top-level keys of the substitutions as ArgoCD parameter announcements
+			(for parameters.dynamic of the plugin). Maps are announced with flattened keys (eg. app.replicas).
+			Parameters set on the application override the substitutions with the same name on render.
+			Secrets are not announced.`),
+		Example: `# Announce the parameters of the local directory
+subst parameters`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: parameters,
+	}
+
+	flags := cmd.Flags()
+	addCommonFlags(flags)
+	addSubstitutionFlags(flags)
+	return cmd
+}
+
+func parameters(cmd *cobra.Command, args []string) error {
+	dir, err := rootDirectory(args)
+	if err != nil {
+		return err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, dir)
+	if err != nil {
+		return fmt.Errorf("failed loading configuration: %w", err)
+	}
+
+	m, err := subst.NewSubst(*configuration)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m.Parameters())
+}
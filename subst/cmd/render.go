@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/MakeNowJust/heredoc"
+	"github.com/kubelize/subst/internal/output"
 	"github.com/kubelize/subst/internal/utils"
 	"github.com/kubelize/subst/pkg/config"
 	"github.com/kubelize/subst/pkg/subst"
@@ -28,23 +30,85 @@ subst render ../examples/02-overlays/clusters/cluster-01`,
 
 	flags := cmd.Flags()
 	addCommonFlags(flags)
-	addRenderFlags(flags)
+	addSubstitutionFlags(flags)
+	addBuildFlags(flags)
+	addOutputFlags(flags)
 	return cmd
 }
 
-func addRenderFlags(flags *flag.FlagSet) {
+// addSubstitutionFlags adds the flags which affect the loaded substitutions (environment, secrets, overrides, sandbox)
+func addSubstitutionFlags(flags *flag.FlagSet) {
 	flags.StringSlice("ejson-key", []string{}, heredoc.Doc(`
 			Specify EJSON Private key used for decryption.
 			May be specified multiple times or separate values with commas`))
+	flags.StringSlice("sops-age-key-file", []string{}, heredoc.Doc(`
+			Specify age key file used for SOPS decryption.
+			May be specified multiple times or separate values with commas`))
 	flags.Bool("skip-decrypt", false, heredoc.Doc(`
 			Skip decryption`))
+	flags.Bool("ejson-merge", false, heredoc.Doc(`
+			Additionally merge the content of all ejson files directly into the .ejson namespace`))
 	flags.String("env-regex", "^ARGOCD_ENV_.*$", heredoc.Doc(`
 	        Only expose environment variables that match the given regex`))
-	flags.String("output", "yaml", heredoc.Doc(`
-	        Output format. One of: yaml, json`))
+	flags.StringSlice("template-env", []string{}, heredoc.Doc(`
+	        Additional environment variables readable by templates (eg. with getenv), besides the ones matching --env-regex.
+	        Without the sandbox templates can still read the environment from files (eg. /proc/self/environ).
+	        May be specified multiple times or separate values with commas`))
+	flags.String("sandbox", "auto", heredoc.Doc(`
+	        Restrict the template functions for untrusted templates: files can only be read in the repository,
+	        network, cloud metadata and environment functions are blocked.
+	        One of: auto (enabled when running as ArgoCD plugin), true, false`))
+	flags.String("env-separator", "__", heredoc.Doc(`
+	        Separator of nested keys in environment variables (eg. ARGOCD_ENV_settings__app__replicas=3
+	        sets settings.app.replicas to 3). Values are parsed as YAML. Empty disables nested keys`))
+	flags.Bool("spruce", false, heredoc.Doc(`
+	        Evaluate spruce operators (grab, concat, stringify, join) against the substitutions`))
+	flags.StringSlice("values", []string{}, heredoc.Doc(`
+	        YAML files with substitutions, merged on top of all subst.yaml files, secrets and environment variables.
+	        May be specified multiple times or separate values with commas`))
+	flags.StringArray("set", []string{}, heredoc.Doc(`
+	        Override substitutions (eg. cluster.name=foo,app.replicas=3,zones[0]=a,list={a,b}).
+	        Booleans, null and integers are typed. Applied after --values, may be specified multiple times`))
+	flags.StringArray("set-string", []string{}, heredoc.Doc(`
+	        Override substitutions with string values (eg. app.version=1.10). Applied after --set`))
+	flags.StringArray("set-file", []string{}, heredoc.Doc(`
+	        Override substitutions with the content of files (eg. ca.crt=certs/ca.crt). Applied after --set-string`))
+}
+
+// addBuildFlags adds the flags which affect the kustomize build and the templating of the resources
+func addBuildFlags(flags *flag.FlagSet) {
 	flags.String("kustomize-build-options", "", heredoc.Doc(`
 	        Additional build options for kustomize. Example: --load-restrictor LoadRestrictionsNone`))
+	flags.Bool("strict", false, heredoc.Doc(`
+	        Fail on references to variables, which are not defined in the substitutions`))
+	flags.String("left-delim", "{{", heredoc.Doc(`
+	        Left template delimiter (eg. [[ to keep {{ }} of embedded Prometheus or Helm templates)`))
+	flags.String("right-delim", "}}", heredoc.Doc(`
+	        Right template delimiter`))
+	flags.StringSlice("passthrough", []string{}, heredoc.Doc(`
+	        File patterns of resources, which are not templated (eg. alerts/*.yaml), matched against the file
+	        the resource was built from. May be specified multiple times or separate values with commas`))
+	flags.Bool("pre-build", false, heredoc.Doc(`
+	        Render the kustomization files and sources with the substitutions before the kustomize build`))
+}
 
+// addOutputFlags adds the flags which only affect the output of render
+func addOutputFlags(flags *flag.FlagSet) {
+	flags.String("output", "yaml", heredoc.Doc(`
+	        Output format. One of: yaml, json`))
+	flags.String("json-mode", utils.JSONModeList, heredoc.Doc(`
+	        Layout of the json output. One of: list (Kubernetes v1/List), array, ndjson (one resource per line)`))
+	flags.String("output-dir", "", heredoc.Doc(`
+	        Write each resource to its own file in the given directory instead of printing to stdout.
+	        Files written by a previous run, which are no longer produced, are removed`))
+	flags.String("output-naming", output.DefaultNaming, heredoc.Doc(`
+	        Naming scheme for files in the output directory.
+	        Placeholders: <namespace> (_cluster for cluster scoped resources), <kind> (lowercase), <name>, <group>, <version>`))
+	flags.Bool("validate", false, heredoc.Doc(`
+	        Validate the rendered resources against the Kubernetes OpenAPI schemas (no cluster access required)`))
+	flags.StringSlice("schema-dir", []string{}, heredoc.Doc(`
+	        Directories or files with additional schemas used for validation (OpenAPI documents, JSON schemas or CRDs).
+	        May be specified multiple times or separate values with commas`))
 }
 
 func render(cmd *cobra.Command, args []string) error {
@@ -60,6 +124,10 @@ func render(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed loading configuration: %w", err)
 	}
 
+	if configuration.Output == "json" && !slices.Contains(utils.JSONModes, configuration.JSONMode) {
+		return fmt.Errorf("invalid json mode %q, must be one of %v", configuration.JSONMode, utils.JSONModes)
+	}
+
 	// Use the new simplified Subst
 	m, err := subst.NewSubst(*configuration)
 	if err != nil {
@@ -71,24 +139,37 @@ func render(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		if m.Manifests != nil {
+		if configuration.Validate {
+			err = m.Validate()
+			if err != nil {
+				return err
+			}
+		}
+		if configuration.OutputDir != "" {
+			index, err := output.WriteDirectory(configuration.OutputDir, configuration.OutputNaming, m.Manifests)
+			if err != nil {
+				return fmt.Errorf("failed to write output directory: %w", err)
+			}
+			log.Info().Msgf("Wrote %d resource(s) to %s", len(index.Files), configuration.OutputDir)
+		} else if configuration.Output == "json" {
+			// Collect the documents of all manifests, so they are printed as a single JSON value
+			var documents []interface{}
+			for _, f := range m.Manifests {
+				docs, err := utils.UnmarshalYAMLDocuments(f)
+				if err != nil {
+					return fmt.Errorf("failed to unmarshal for JSON: %w", err)
+				}
+				documents = append(documents, docs...)
+			}
+			err = utils.PrintJSONDocuments(documents, configuration.JSONMode)
+			if err != nil {
+				return fmt.Errorf("failed to print JSON: %w", err)
+			}
+		} else {
 			for _, f := range m.Manifests {
-				if configuration.Output == "json" {
-					// Convert bytes to map for JSON output
-					var data map[interface{}]interface{}
-					if err := utils.UnmarshalJSONorYAMLToInterface(f, &data); err != nil {
-						log.Error().Msgf("failed to unmarshal for JSON: %s", err)
-						continue
-					}
-					err = utils.PrintJSON(data)
-					if err != nil {
-						log.Error().Msgf("failed to print JSON: %s", err)
-					}
-				} else {
-					err = utils.PrintYAMLBytes(f)
-					if err != nil {
-						log.Error().Msgf("failed to print YAML: %s", err)
-					}
+				err = utils.PrintYAMLBytes(f)
+				if err != nil {
+					log.Error().Msgf("failed to print YAML: %s", err)
 				}
 			}
 		}
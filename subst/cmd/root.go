@@ -52,6 +52,10 @@ func NewRootCmd() *cobra.Command {
 	cmd.AddCommand(newDiscoverCmd())
 	cmd.AddCommand(newVersionCmd())
 	cmd.AddCommand(newRenderCmd())
+	cmd.AddCommand(newVarsCmd())
+	cmd.AddCommand(newDiffCmd())
+	cmd.AddCommand(newMigrateCmd())
+	cmd.AddCommand(newParametersCmd())
 
 	cmd.DisableAutoGenTag = true
 
@@ -89,23 +93,23 @@ func setUpMaxProcs(procs int) error {
 }
 
 func addCommonFlags(flags *flag.FlagSet) {
-	flags.StringVar(&cfgFile, "config", "", "Config file")
+	flags.StringVar(&cfgFile, "config", "", heredoc.Doc(`
+			Config file (default: .subst.yaml discovered upwards from the root directory)`))
 	flags.Bool("debug", false, heredoc.Doc(`
 			Print CLI calls of external tools to stdout (caution: setting this may
 			expose sensitive data)`))
 }
 
+// rootDirectory returns the absolute directory given as argument,
+// or an empty string to use the root directory from the configuration
 func rootDirectory(args []string) (directory string, err error) {
-	directory = "."
-	if len(args) > 0 {
-		directory = args[0]
+	if len(args) == 0 {
+		return "", nil
 	}
-	rootAbs, err := filepath.Abs(directory)
+	rootAbs, err := filepath.Abs(args[0])
 	if err != nil {
 		return "", fmt.Errorf("failed resolving root directory: %w", err)
-	} else {
-		directory = rootAbs
 	}
 
-	return directory, nil
+	return rootAbs, nil
 }
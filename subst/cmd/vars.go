@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/kubelize/subst/pkg/config"
+	"github.com/kubelize/subst/pkg/subst"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "<redacted>"
+
+func newVarsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "vars [dir]",
+		Short: "Print the substitution context with the source of each value",
+		Long: heredoc.Doc(`
+			Run 'subst vars' to print the merged substitutions available to templates. Each value is annotated with
+			the subst.yaml, ejson or sops file or environment variable it was loaded from and the sources it overrode.
+			Decrypted secret values and copies of them are redacted, unless --show-secrets is set.`),
+		Example: `# Print the substitutions of the local directory
+subst vars
+# Print the substitutions as JSON
+subst vars --output json ../examples/02-overlays/clusters/cluster-01`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: vars,
+	}
+
+	flags := cmd.Flags()
+	addCommonFlags(flags)
+	addSubstitutionFlags(flags)
+	flags.String("output", "yaml", heredoc.Doc(`
+	        Output format. One of: yaml, json`))
+	flags.Bool("show-secrets", false, heredoc.Doc(`
+	        Print decrypted secret values instead of redacting them`))
+	return cmd
+}
+
+// varSource is the provenance of a single value in the JSON output
+type varSource struct {
+	Source    string   `json:"source"`
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+func vars(cmd *cobra.Command, args []string) error {
+	dir, err := rootDirectory(args)
+	if err != nil {
+		return err
+	}
+
+	configuration, err := config.LoadConfiguration(cfgFile, cmd, dir)
+	if err != nil {
+		return fmt.Errorf("failed loading configuration: %w", err)
+	}
+
+	showSecrets, err := cmd.Flags().GetBool("show-secrets")
+	if err != nil {
+		return err
+	}
+
+	m, err := subst.NewSubst(*configuration)
+	if err != nil {
+		return err
+	}
+
+	p := &varsPrinter{
+		provenance:  m.Provenance,
+		secret:      m.IsSecret,
+		root:        configuration.RootDirectory,
+		showSecrets: showSecrets,
+	}
+
+	if configuration.Output == "json" {
+		values, sources := p.jsonValues("", m.Substitutions)
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{
+			"values":  values,
+			"sources": sources,
+		})
+	}
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(p.yamlNode("", m.Substitutions))
+}
+
+type varsPrinter struct {
+	provenance  subst.Provenance
+	secret      func(value interface{}) bool // Reports copies of decrypted secret values
+	root        string
+	showSecrets bool
+}
+
+// yamlNode converts the value at path into a YAML node, annotating leaves with their sources
+func (p *varsPrinter) yamlNode(path string, value interface{}) *yaml.Node {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range sortedKeys(m) {
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+			valueNode := p.yamlNode(joinPath(path, key), m[key])
+			if valueNode.Kind != yaml.ScalarNode {
+				// Comments of collections are placed on the key
+				keyNode.LineComment, valueNode.LineComment = valueNode.LineComment, ""
+			}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return node
+	}
+
+	value, comment := p.leaf(path, value)
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		node = &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(value)}
+	}
+	node.LineComment = comment
+	return node
+}
+
+// jsonValues returns the values at path and the sources of all leaves by dotted path
+func (p *varsPrinter) jsonValues(path string, value interface{}) (interface{}, map[string]varSource) {
+	sources := map[string]varSource{}
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		values := make(map[string]interface{}, len(m))
+		for key, v := range m {
+			childValue, childSources := p.jsonValues(joinPath(path, key), v)
+			values[key] = childValue
+			for k, s := range childSources {
+				sources[k] = s
+			}
+		}
+		return values, sources
+	}
+
+	leafSources := p.provenance.Sources(path)
+	if len(leafSources) > 0 {
+		source := varSource{Source: p.sourceName(leafSources[len(leafSources)-1])}
+		for _, s := range leafSources[:len(leafSources)-1] {
+			source.Overrides = append(source.Overrides, p.sourceName(s))
+		}
+		sources[path] = source
+	}
+	value, _ = p.leaf(path, value)
+	return value, sources
+}
+
+// leaf returns the (redacted) value at path and a comment describing its sources
+func (p *varsPrinter) leaf(path string, value interface{}) (interface{}, string) {
+	sources := p.provenance.Sources(path)
+	secret := p.secret(value) || (len(sources) > 0 && sources[len(sources)-1].Secret)
+	if secret && !p.showSecrets {
+		value = redacted
+	}
+	if len(sources) == 0 {
+		return value, ""
+	}
+
+	current := sources[len(sources)-1]
+	comment := "from " + p.sourceName(current)
+	if len(sources) > 1 {
+		var overridden []string
+		for _, s := range sources[:len(sources)-1] {
+			overridden = append(overridden, p.sourceName(s))
+		}
+		comment += ", overrides " + strings.Join(overridden, ", ")
+	}
+	return value, comment
+}
+
+// sourceName returns file sources relative to the root directory
+func (p *varsPrinter) sourceName(source subst.Source) string {
+	if !filepath.IsAbs(source.Name) {
+		return source.Name
+	}
+	if rel, err := filepath.Rel(p.root, source.Name); err == nil {
+		return rel
+	}
+	return source.Name
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}